@@ -4,11 +4,12 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
 	"log"
-	"os"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
-	f "github.com/kyoshidaxx/tsunagi/internal/datastore/file"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
 	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
 	"github.com/kyoshidaxx/tsunagi/internal/utils"
 	"github.com/spf13/cobra"
@@ -19,6 +20,30 @@ var region string
 var instanceName string
 var port int
 var name string
+var engine string
+var database string
+var user string
+var passwordSecret string
+var autoIAMAuthn bool
+var ipType string
+var impersonateServiceAccount string
+var gcloudConfiguration string
+var credentialsFile string
+var healthCheckPort int
+var idleTimeout string
+var maxSessionDuration string
+var environment string
+var requireReason bool
+var group string
+
+// engineDetect is the engine option that leaves the engine to be detected from the instance.
+const engineDetect = "detect from instance"
+
+var ipTypeDescriptions = map[cloud.IPType]string{
+	cloud.IPTypePublic:  "connect over the public IP",
+	cloud.IPTypePrivate: "requires a network connected to the VPC",
+	cloud.IPTypePSC:     "Private Service Connect, requires a network connected to the endpoint",
+}
 
 // addCmd represents the add command
 var addCmd = &cobra.Command{
@@ -30,9 +55,19 @@ Use Cloud SQL Auth Proxy based on the saved connection information`,
 		// gcloud command check
 		err := utils.CheckGcloudCmd()
 		if err != nil {
+			log.Fatal(err)
 			return
 		}
 
+		// Without a terminal the values that have no default must be passed as flags
+		if !interactive() {
+			err := requireFlags(cmd, "project", "region", "instance", "port", "name")
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
 		if projectID == "" {
 			prompt := &survey.Input{
 				Message: "Enter Project ID",
@@ -89,15 +124,115 @@ Use Cloud SQL Auth Proxy based on the saved connection information`,
 			}
 		}
 
-		r := f.NewConfigFileRepository(os.Getenv("CONFIG_FILE_PATH"))
-		c := config.NewConfig(r)
+		if !cmd.Flags().Changed("environment") && interactive() {
+			prompt := &survey.Select{
+				Message: "Select Environment",
+				Options: environmentOptions(),
+				Default: environment,
+			}
+			err := survey.AskOne(prompt, &environment)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
+		if !cmd.Flags().Changed("require-reason") && environment == string(config.EnvironmentProd) && interactive() {
+			prompt := &survey.Confirm{
+				Message: "Require a reason to start the proxy?",
+			}
+			err := survey.AskOne(prompt, &requireReason)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
+		if !cmd.Flags().Changed("ip-type") && interactive() {
+			prompt := &survey.Select{
+				Message: "Select IP Type",
+				Options: ipTypeOptions(),
+				Description: func(value string, index int) string {
+					return ipTypeDescriptions[cloud.IPType(value)]
+				},
+			}
+			err := survey.AskOne(prompt, &ipType)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
+		if engine == "" && interactive() {
+			prompt := &survey.Select{
+				Message: "Select Database Engine",
+				Options: append([]string{engineDetect}, engineOptions()...),
+			}
+			err := survey.AskOne(prompt, &engine)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			if engine == engineDetect {
+				engine = ""
+			}
+		}
+
+		if !cmd.Flags().Changed("auto-iam-authn") && cloud.SupportsIAMAuthn(cloud.Engine(engine)) && interactive() {
+			prompt := &survey.Confirm{
+				Message: "Use IAM Database Authentication?",
+			}
+			err := survey.AskOne(prompt, &autoIAMAuthn)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
+		if database == "" && engine != "" && interactive() {
+			prompt := &survey.Input{
+				Message: "Enter Database Name (optional)",
+			}
+			err := survey.AskOne(prompt, &database)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
 
-		err = c.Add(config.ConfigParam{
-			Name:         name,
-			Port:         port,
-			ProjectName:  projectID,
-			Region:       region,
-			InstanceName: instanceName,
+		// with IAM authentication the user is derived from the gcloud account when left empty
+		if user == "" && engine != "" && !autoIAMAuthn && interactive() {
+			prompt := &survey.Input{
+				Message: "Enter Database User (optional)",
+			}
+			err := survey.AskOne(prompt, &user)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
+		err = newConfig().Add(config.ConfigParam{
+			Name:                      name,
+			Port:                      port,
+			ProjectName:               projectID,
+			Region:                    region,
+			InstanceName:              instanceName,
+			Engine:                    cloud.Engine(engine),
+			Database:                  database,
+			User:                      user,
+			PasswordSecret:            passwordSecret,
+			AutoIAMAuthn:              autoIAMAuthn,
+			IPType:                    cloud.IPType(ipType),
+			ImpersonateServiceAccount: impersonateServiceAccount,
+			GcloudConfiguration:       gcloudConfiguration,
+			CredentialsFile:           credentialsFile,
+			HealthCheckPort:           healthCheckPort,
+			IdleTimeout:               idleTimeout,
+			MaxSessionDuration:        maxSessionDuration,
+			Environment:               config.Environment(environment),
+			RequireReason:             requireReason,
+			Group:                     group,
 		})
 
 		if err != nil {
@@ -107,6 +242,45 @@ Use Cloud SQL Auth Proxy based on the saved connection information`,
 	},
 }
 
+// requireFlags returns an error naming the flags that were left empty.
+func requireFlags(cmd *cobra.Command, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		value := cmd.Flags().Lookup(name).Value.String()
+		if value == "" || value == "0" {
+			missing = append(missing, "--"+name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("flags required without a terminal: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func environmentOptions() []string {
+	var options []string
+	for _, e := range config.GetEnvironmentList() {
+		options = append(options, string(e))
+	}
+	return options
+}
+
+func ipTypeOptions() []string {
+	var options []string
+	for _, t := range cloud.GetIPTypeList() {
+		options = append(options, string(t))
+	}
+	return options
+}
+
+func engineOptions() []string {
+	var options []string
+	for _, e := range cloud.GetEngineList() {
+		options = append(options, string(e))
+	}
+	return options
+}
+
 func init() {
 	rootCmd.AddCommand(addCmd)
 
@@ -115,4 +289,25 @@ func init() {
 	addCmd.Flags().StringVarP(&instanceName, "instance", "i", "", "Instance name")
 	addCmd.Flags().IntVarP(&port, "port", "o", 0, "Port")
 	addCmd.Flags().StringVarP(&name, "name", "n", "", "Name")
+	addCmd.Flags().StringVar(&ipType, "ip-type", string(cloud.IPTypePublic), "IP type (public, private, psc)")
+	addCmd.Flags().StringVarP(&engine, "engine", "e", "", "Database engine (postgres, mysql, sqlserver)")
+	addCmd.Flags().StringVarP(&database, "database", "d", "", "Database name")
+	addCmd.Flags().StringVarP(&user, "user", "u", "", "Database user")
+	addCmd.Flags().BoolVar(&autoIAMAuthn, "auto-iam-authn", false, "Use IAM database authentication")
+	addCmd.Flags().StringVar(&impersonateServiceAccount, "impersonate-service-account", "", "Service account to impersonate, or a comma separated delegation chain ending with it")
+	addCmd.Flags().StringVar(&gcloudConfiguration, "gcloud-configuration", "", "gcloud configuration to use instead of the active one")
+	addCmd.Flags().StringVar(&credentialsFile, "credentials-file", "", "Service account key file to use instead of Application Default Credentials")
+	addCmd.Flags().IntVar(&healthCheckPort, "health-check-port", 0, "Port for the proxy's HTTP health check endpoints, checked by proxyStatus")
+	addCmd.Flags().StringVar(&idleTimeout, "idle-timeout", "", "Stop the proxy after this long without connections, e.g. 30m (0 to never stop, overriding IDLE_TIMEOUT)")
+	addCmd.Flags().StringVar(&maxSessionDuration, "max-session-duration", "", "Stop the proxy this long after it starts unless extended, e.g. 1h")
+	addCmd.Flags().StringVar(&environment, "environment", string(config.EnvironmentDev), "Environment (dev, staging, prod)")
+	addCmd.Flags().StringVar(&group, "group", "", "Group to list the config under, such as a team or client")
+	addCmd.Flags().BoolVar(&requireReason, "require-reason", false, "Require a reason, written to the audit log, to start the proxy")
+	addCmd.Flags().StringVar(&passwordSecret, "password-secret", "", "Secret Manager version holding the password (projects/p/secrets/s/versions/v)")
+
+	addCmd.RegisterFlagCompletionFunc("project", completeProjects)
+	addCmd.RegisterFlagCompletionFunc("region", cobra.FixedCompletions(utils.GetRegionList(), cobra.ShellCompDirectiveNoFileComp))
+	addCmd.RegisterFlagCompletionFunc("ip-type", cobra.FixedCompletions(ipTypeOptions(), cobra.ShellCompDirectiveNoFileComp))
+	addCmd.RegisterFlagCompletionFunc("engine", cobra.FixedCompletions(engineOptions(), cobra.ShellCompDirectiveNoFileComp))
+	addCmd.RegisterFlagCompletionFunc("environment", cobra.FixedCompletions(environmentOptions(), cobra.ShellCompDirectiveNoFileComp))
 }
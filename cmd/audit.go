@@ -0,0 +1,90 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/audit"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var auditSince string
+var auditName string
+var auditJSON bool
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the audit log of proxy sessions",
+	Long: `Show the audit log of proxy sessions, oldest first.
+
+Every proxy start and stop is appended as a JSON line to audit.log in the
+directory of the config file, with the config and connection name, the
+gcloud account, the port and the reason given with proxyStart --reason.
+Stops also record how long the session lasted and how it ended.
+
+  tsunagi audit --since 7d --name billing`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := audit.Filter{Name: auditName}
+		if auditSince != "" {
+			age, err := utils.ParseAge(auditSince)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			filter.Since = time.Now().Add(-age)
+		}
+
+		entries, err := newAudit().Find(filter)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		if auditJSON {
+			if entries == nil {
+				entries = []audit.Entry{}
+			}
+			out, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			fmt.Println(string(out))
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tACTION\tNAME\tACCOUNT\tPORT\tDURATION\tSTATUS\tREASON")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+				entry.Time.Local().Format("2006-01-02 15:04:05"),
+				entry.Action,
+				entry.Name,
+				orDash(entry.Account),
+				entry.Port,
+				orDash(entry.Duration),
+				orDash(entry.ExitStatus),
+				orDash(entry.Reason),
+			)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "Only show entries newer than this, e.g. 7d or 12h")
+	auditCmd.Flags().StringVar(&auditName, "name", "", "Only show entries of this config")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "Print the entries as JSON")
+}
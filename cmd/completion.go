@@ -0,0 +1,135 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"log"
+	"os"
+	"slices"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate the autocompletion script for a shell",
+	Long: `Generate the autocompletion script for tsunagi for the given shell. Besides
+commands and flags it completes the names of saved configs, the names of
+running proxies for proxyStop and extend, and regions and projects for add.
+Projects are completed from the saved configs and the gcloud configurations.
+
+Bash, which requires the bash-completion package:
+
+  source <(tsunagi completion bash)
+
+Zsh, with compinit enabled:
+
+  tsunagi completion zsh > "${fpath[1]}/_tsunagi"
+
+Fish:
+
+  tsunagi completion fish > ~/.config/fish/completions/tsunagi.fish
+
+PowerShell:
+
+  tsunagi completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch args[0] {
+		case "bash":
+			err = rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			err = rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			err = rootCmd.GenFishCompletion(os.Stdout, true)
+		default:
+			err = rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// completeConfigNames completes the first argument with the names of the saved configs.
+func completeConfigNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	params, err := newConfig().List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, param := range params {
+		names = append(names, param.Name+"\t"+describeConfig(param))
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRunningNames completes the first argument with the names of the running proxies.
+func completeRunningNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	states, err := proxyStates()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, state := range states {
+		if state.Alive() {
+			names = append(names, state.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeExecArgs completes the config name of exec, leaving the command after it to the shell.
+func completeExecArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return completeConfigNames(cmd, args, toComplete)
+}
+
+// describeConfig returns the description shown next to the name of the config by shells that
+// support descriptions.
+func describeConfig(param config.ConfigParam) string {
+	description := cloud.ConnectionName(param.ProjectName, param.Region, param.InstanceName)
+	if param.Environment != "" {
+		description = string(param.Environment) + " " + description
+	}
+	return description
+}
+
+// completeProjects completes projects with those of the saved configs and of the gcloud
+// configurations. gcloud is not required, its projects are left out when it fails.
+func completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var projects []string
+	params, err := newConfig().List()
+	if err == nil {
+		for _, param := range params {
+			projects = append(projects, param.ProjectName)
+		}
+	}
+	configured, err := utils.GetConfiguredProjects()
+	if err == nil {
+		projects = append(projects, configured...)
+	}
+	slices.Sort(projects)
+	return slices.Compact(projects), cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.AddCommand(completionCmd)
+}
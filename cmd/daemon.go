@@ -0,0 +1,112 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/daemon"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/metrics"
+	"github.com/spf13/cobra"
+)
+
+var metricsAddr string
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run tsunagi in the foreground, supervising proxies",
+	Long: `Run tsunagi in the foreground as a daemon that owns the proxies it starts,
+restarting them with backoff when they exit. It is controlled through a JSON
+API on a Unix socket next to the config file.
+
+While the daemon runs, proxyStart, proxyStop and proxyStatus talk to it
+instead of managing the proxies themselves. Proxies started with proxyStart
+are restarted as its --restart and --max-retries say, and expired gcloud
+credentials are renewed as without the daemon. Stopping the daemon stops the
+proxies it started.
+
+With --metrics-addr the daemon serves Prometheus metrics of each config on
+/metrics: whether its proxy is up, restarts, session duration, active
+connections, bytes in and out and connection errors.
+
+  tsunagi daemon --metrics-addr 127.0.0.1:9464`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := socketPath()
+		if daemon.SocketAvailable(path) {
+			log.Fatal("daemon is already running")
+			return
+		}
+		// A socket left behind by a daemon that did not shut down cleanly
+		os.Remove(path)
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		defer os.Remove(path)
+		err = os.Chmod(path, 0600)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		server := daemon.NewServer(newProxyStateRepository(), newProxyWith, getProxyConfig)
+		server.SetLogStore(newLogStore())
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Fprintf(os.Stderr, "tsunagi daemon listening on %s\n", path)
+		if metricsAddr != "" {
+			ml, err := net.Listen("tcp", metricsAddr)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler(configNames, server.Status))
+			metricsServer := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+			go metricsServer.Serve(ml)
+			defer metricsServer.Close()
+			fmt.Fprintf(os.Stderr, "serving metrics on http://%s/metrics\n", ml.Addr())
+		}
+		err = server.Serve(ctx, l)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// configNames returns the names of the saved configs.
+func configNames() ([]string, error) {
+	params, err := newConfig().List()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(params))
+	for _, param := range params {
+		names = append(names, param.Name)
+	}
+	return names, nil
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Address to serve Prometheus metrics on, e.g. 127.0.0.1:9464")
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	f "github.com/kyoshidaxx/tsunagi/internal/datastore/file"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/doctor"
+	"github.com/spf13/cobra"
+)
+
+var doctorJSON bool
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the local environment",
+	Long: `Diagnose the local environment: gcloud and its credentials, the
+cloud-sql-proxy binary, the config file, duplicated names and ports, ports
+already in use, stale proxy state and file permissions.
+
+Every check is reported as pass, warn or fail with a hint on how to fix it.
+The command exits with a non-zero status when any check fails.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := os.Getenv("CONFIG_FILE_PATH")
+		dir := dataDir()
+		states := newProxyStateRepository()
+		// A broken proxy selection is reported by the proxy check instead of aborting the run
+		p, proxyErr := loadProxy(states)
+		d := doctor.NewDoctor(
+			f.NewConfigFileRepository(configPath),
+			states,
+			p,
+			doctor.File{Path: filepath.Join(dir, filepath.Base(configPath)), Forbidden: 0022},
+			doctor.File{Path: filepath.Join(dir, "secrets"), Forbidden: 0077},
+			doctor.File{Path: filepath.Join(dir, "run"), Forbidden: 0077},
+		)
+
+		if proxyErr != nil {
+			d.SetProxyError(proxyErr)
+		}
+		results := d.Run()
+		if doctorJSON {
+			out, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			fmt.Println(string(out))
+		} else {
+			for _, r := range results {
+				fmt.Printf("[%s] %s: %s\n", strings.ToUpper(string(r.Status)), r.Check, r.Message)
+				if r.Hint != "" {
+					fmt.Printf("       %s\n", r.Hint)
+				}
+			}
+		}
+		if doctor.Failed(results) {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Print the results as JSON")
+}
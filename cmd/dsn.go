@@ -0,0 +1,65 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/connection"
+	"github.com/spf13/cobra"
+)
+
+var dsnFormat string
+var withPassword bool
+
+// dsnCmd represents the dsn command
+var dsnCmd = &cobra.Command{
+	Use:   "dsn <name>",
+	Short: "Print a connection string for a saved config",
+	Long: `Print a connection string for a saved config, pointing at the local
+Cloud SQL Auth Proxy port with the saved database and user.
+
+The password is never included unless --with-password is given, in which
+case it is read from the config's secret source.
+
+  tsunagi dsn billing --format jdbc`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		param, err := getConnectionConfig(args[0])
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		info := connection.NewInfo(param)
+		if withPassword {
+			info.Password, err = resolvePassword(param)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
+		dsn, err := info.DSN(connection.Format(dsnFormat))
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Println(dsn)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dsnCmd)
+
+	var formats []string
+	for _, f := range connection.GetFormatList() {
+		formats = append(formats, string(f))
+	}
+	dsnCmd.Flags().StringVarP(&dsnFormat, "format", "f", string(connection.FormatURL), "Output format ("+strings.Join(formats, ", ")+")")
+	dsnCmd.Flags().BoolVar(&withPassword, "with-password", false, "Include the password from the secret source")
+}
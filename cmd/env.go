@@ -0,0 +1,65 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/connection"
+	"github.com/spf13/cobra"
+)
+
+var envFormat string
+var envPrefix string
+var envWithPassword bool
+
+// envCmd represents the env command
+var envCmd = &cobra.Command{
+	Use:   "env <name>",
+	Short: "Print the connection environment variables for a saved config",
+	Long: `Print the connection environment variables for a saved config
+(DATABASE_URL, PGHOST/PGPORT/... or MYSQL_HOST/MYSQL_TCP_PORT).
+
+  eval "$(tsunagi env billing)"
+  tsunagi env billing --format direnv --prefix billing >> .envrc`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		param, err := getConnectionConfig(args[0])
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		info := connection.NewInfo(param)
+		if envWithPassword {
+			info.Password, err = resolvePassword(param)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
+		out, err := connection.RenderEnv(connection.WithPrefix(info.Vars(), envPrefix), connection.EnvFormat(envFormat))
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Print(out)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+
+	var formats []string
+	for _, f := range connection.GetEnvFormatList() {
+		formats = append(formats, string(f))
+	}
+	envCmd.Flags().StringVarP(&envFormat, "format", "f", string(connection.EnvFormatShell), "Output format ("+strings.Join(formats, ", ")+")")
+	envCmd.Flags().StringVar(&envPrefix, "prefix", "", "Prefix for the variable names")
+	envCmd.Flags().BoolVar(&envWithPassword, "with-password", false, "Include the password from the secret source")
+}
@@ -0,0 +1,144 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/connection"
+	"github.com/spf13/cobra"
+)
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec <name> -- <command> [args...]",
+	Short: "Run a command with the connection environment of a saved config",
+	Long: `Run a command with the connection environment of a saved config.
+The Cloud SQL Auth Proxy is started if it is not running, and stopped again
+after the command exits if tsunagi started it. Its output is written to the
+config's log file, see tsunagi logs.
+
+The command receives DATABASE_URL and PGHOST/PGPORT/PGDATABASE/PGUSER
+(PostgreSQL) or MYSQL_HOST/MYSQL_TCP_PORT (MySQL), plus PGPASSWORD or
+MYSQL_PWD when a password source is configured for the config. SIGTERM and
+SIGHUP are forwarded to the command, while Ctrl-C reaches it from the terminal
+directly. tsunagi exits with the command's exit code.
+
+When tsunagi daemon runs, the proxy is started and stopped through the daemon.
+
+Starting the proxy of a prod config asks for the same confirmation as
+proxyStart, see proxyStart --help.
+
+  tsunagi exec billing -- go run ./migrate`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() != 1 || len(args) < 2 {
+			return errors.New("requires a config name followed by -- and a command")
+		}
+		return nil
+	},
+	ValidArgsFunction: completeExecArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		param, err := getConnectionConfig(args[0])
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		info := connection.NewInfo(param)
+		if param.HasPassword() {
+			info.Password, err = resolvePassword(param)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
+		state, err := newProxy().Running(param.Name)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		started := state == nil
+		if started {
+			reason, err := guardStart(param)
+			if err != nil {
+				fatal(err)
+				return
+			}
+			_, err = startProxy(starter(param, 0, 0), interactive())
+			if err != nil {
+				fatal(err)
+				return
+			}
+			recordStart(param, reason)
+		}
+
+		code := runChild(args[1], args[2:], info.Env())
+
+		if started {
+			err = stopProxy(param.Name)
+			if err != nil {
+				log.Print(err)
+			}
+		}
+		os.Exit(code)
+	},
+}
+
+// runChild runs the command with the extra environment, forwarding the signals that do not
+// come from the terminal to it,
+// and returns the exit code tsunagi should exit with.
+func runChild(name string, args []string, env []string) int {
+	child := exec.Command(name, args...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	child.Env = append(os.Environ(), env...)
+
+	err := child.Start()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 127
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			// The terminal sends Ctrl-C to the child too, as it runs in tsunagi's process group.
+			// Sending it again would make clients such as psql exit instead of cancelling a query.
+			if sig == os.Interrupt {
+				continue
+			}
+			child.Process.Signal(sig)
+		}
+	}()
+
+	err = child.Wait()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	status, ok := child.ProcessState.Sys().(syscall.WaitStatus)
+	if ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return child.ProcessState.ExitCode()
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	addStartGuardFlags(execCmd)
+}
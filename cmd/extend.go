@@ -0,0 +1,51 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// extendCmd represents the extend command
+var extendCmd = &cobra.Command{
+	Use:   "extend <name> <duration>",
+	Short: "Extend the session of a time-boxed proxy",
+	Long: `Move the end of a time-boxed proxy's session later. The time left cannot
+exceed the config's max session duration.
+
+  tsunagi extend billing 15m`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeRunningNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			log.Fatal("duration is not valid")
+			return
+		}
+		param, err := getProxyConfig(args[0])
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		state, err := newProxy().Extend(param, d)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Printf("Extended the session of %q until %s (%s left)\n",
+			state.Name,
+			state.ExpiresAt.Local().Format("15:04:05"),
+			time.Until(state.ExpiresAt).Round(time.Second),
+		)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(extendCmd)
+}
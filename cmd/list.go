@@ -1,40 +1,79 @@
 /*
 Copyright © 2025 NAME HERE <EMAIL ADDRESS>
-
 */
 package cmd
 
 import (
 	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
 
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Short: "List saved connection information",
+	Long: `List saved connection information.
+The IP column shows how the proxy reaches the instance. private and psc
+connections only work from a network connected to the VPC. prod configs are
+shown in red.`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("list called")
+		params, err := newConfig().List()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		color := term.IsTerminal(int(os.Stdout.Fd()))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, colored(color, false, "NAME\tCONNECTION\tPORT\tENGINE\tIP\tENV"))
+		for _, param := range params {
+			fmt.Fprintln(w, colored(color, param.IsProd(), fmt.Sprintf("%s\t%s\t%d\t%s\t%s\t%s",
+				param.Name,
+				cloud.ConnectionName(param.ProjectName, param.Region, param.InstanceName),
+				param.Port,
+				orDash(string(param.Engine)),
+				ipTypeOf(param),
+				orDash(string(param.Environment)),
+			)))
+		}
+		w.Flush()
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(listCmd)
+// colored returns the line in red when red is true. Every line gets escape sequences of the
+// same length so that tabwriter keeps the columns aligned.
+func colored(color bool, red bool, line string) string {
+	if !color {
+		return line
+	}
+	if red {
+		return "\x1b[31m" + line + "\x1b[0m"
+	}
+	return "\x1b[39m" + line + "\x1b[0m"
+}
 
-	// Here you will define your flags and configuration settings.
+func ipTypeOf(param config.ConfigParam) cloud.IPType {
+	if param.IPType == "" {
+		return cloud.IPTypePublic
+	}
+	return param.IPType
+}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// listCmd.PersistentFlags().String("foo", "", "A help for foo")
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// listCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+func init() {
+	rootCmd.AddCommand(listCmd)
 }
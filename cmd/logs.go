@@ -0,0 +1,65 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/logs"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var logsFollow bool
+var logsSince string
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs <name>",
+	Short: "Show the output of the proxy of a saved config",
+	Long: `Show the output of the proxy of a saved config, with the time tsunagi
+received each line.
+
+The output of proxies started by proxyStart, exec and the daemon is written to
+logs/<name>.log in the directory of the config file. The file is rotated when
+it grows beyond LOG_MAX_SIZE_MB megabytes (10 by default), keeping LOG_KEEP
+rotated files (3 by default).
+
+  tsunagi logs billing -f --since 10m`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		var since time.Time
+		if logsSince != "" {
+			age, err := utils.ParseAge(logsSince)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			since = time.Now().Add(-age)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err := newLogStore().Tail(ctx, args[0], since, logsFollow, func(line logs.Line) {
+			fmt.Println(line)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep printing new output")
+	logsCmd.Flags().StringVar(&logsSince, "since", "", "Only show output newer than this, e.g. 10m or 1d")
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+)
+
+var passwordKey string
+var passwordSecretName string
+
+// passwordCmd represents the password command
+var passwordCmd = &cobra.Command{
+	Use:   "password",
+	Short: "Manage database passwords in the secret store",
+	Long: `Manage database passwords in the secret store.
+Passwords are never written to the config file. The config only keeps the
+key of the password in the secret store selected by SECRET_BACKEND:
+
+  file            passphrase encrypted file next to the config file (default)
+  secret-service  Linux Secret Service (GNOME Keyring, KWallet) via secret-tool
+
+The file passphrase is read from TSUNAGI_PASSPHRASE or asked for. With
+--non-interactive, or without a terminal, it is only read from
+TSUNAGI_PASSPHRASE and "password set" reads the password from the first line
+of stdin:
+
+  printf '%s\n' "$DB_PASSWORD" | tsunagi password set billing --non-interactive
+
+Instead of storing a password locally, a config can reference a Google
+Secret Manager version with "password set <name> --secret", which is read
+through gcloud whenever the password is needed and never written to disk.`,
+}
+
+// passwordSetCmd represents the password set command
+var passwordSetCmd = &cobra.Command{
+	Use:               "set <name>",
+	Short:             "Store the database password of a saved config",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := newConfig()
+		param, err := c.Get(args[0])
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		if passwordSecretName != "" {
+			param.PasswordKey = ""
+			param.PasswordSecret = passwordSecretName
+			err = c.Update(param)
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		password, err := readPassword()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		store, err := newSecretStore()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		key := passwordKey
+		if key == "" {
+			key = param.Name
+		}
+		err = store.Set(key, password)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		param.PasswordKey = key
+		param.PasswordSecret = ""
+		err = c.Update(param)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+	},
+}
+
+// readPassword asks for the database password, or reads it from the first line of stdin
+// when tsunagi may not prompt.
+func readPassword() (string, error) {
+	if !interactive() {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return "", errors.New("password required on stdin without a terminal")
+		}
+		return password, nil
+	}
+	var password string
+	prompt := &survey.Password{
+		Message: "Enter Database Password",
+	}
+	err := survey.AskOne(prompt, &password)
+	return password, err
+}
+
+// passwordDeleteCmd represents the password delete command
+var passwordDeleteCmd = &cobra.Command{
+	Use:               "delete <name>",
+	Short:             "Delete the stored database password of a saved config",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := newConfig()
+		param, err := c.Get(args[0])
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		if param.PasswordSecret != "" {
+			param.PasswordSecret = ""
+			err = c.Update(param)
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		if param.PasswordKey == "" {
+			log.Fatalf("no password is stored for %q", param.Name)
+			return
+		}
+
+		store, err := newSecretStore()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		err = store.Delete(param.PasswordKey)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		param.PasswordKey = ""
+		err = c.Update(param)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(passwordCmd)
+	passwordCmd.AddCommand(passwordSetCmd)
+	passwordCmd.AddCommand(passwordDeleteCmd)
+
+	passwordSetCmd.Flags().StringVarP(&passwordKey, "key", "k", "", "Key in the secret store (default is the config name)")
+	passwordSetCmd.Flags().StringVar(&passwordSecretName, "secret", "", "Reference a Secret Manager version instead of storing the password")
+}
@@ -0,0 +1,22 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// proxyCmd represents the proxy command
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Manage the cloud-sql-proxy binary",
+	Long: `Manage cloud-sql-proxy releases downloaded by tsunagi.
+
+proxyStart runs the version selected with "proxy use", or the
+cloud-sql-proxy found on PATH when none is selected.`,
+}
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/spf13/cobra"
+)
+
+var installVersion string
+var installSHA256 string
+
+// proxyInstallCmd represents the proxy install command
+var proxyInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Download cloud-sql-proxy for this platform",
+	Long: `Download the cloud-sql-proxy release for this OS and architecture into the
+tsunagi data directory, verify its checksum and select it for proxyStart.
+
+The SHA-256 of the download is compared with --sha256, or with the sum
+listed in the release notes on GitHub. The version is not installed when
+neither is available.
+
+Set PROXY_DOWNLOAD_URL to download from a mirror. The sums are still read
+from the GitHub releases API, unless PROXY_CHECKSUM_URL points to another
+copy of it you trust.
+
+  tsunagi proxy install --version v2.14.0`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		version, err := proxy.NormalizeVersion(installVersion)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		path, err := newInstaller().Install(version, installSHA256)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Printf("installed cloud-sql-proxy %s to %s\n", version, path)
+	},
+}
+
+func init() {
+	proxyCmd.AddCommand(proxyInstallCmd)
+
+	proxyInstallCmd.Flags().StringVar(&installVersion, "version", proxy.DefaultVersion, "Version to install")
+	proxyInstallCmd.Flags().StringVar(&installSHA256, "sha256", "", "Expected SHA-256 checksum of the download")
+}
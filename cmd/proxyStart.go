@@ -1,40 +1,237 @@
 /*
 Copyright © 2025 NAME HERE <EMAIL ADDRESS>
-
 */
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
 
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/daemon"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
 	"github.com/spf13/cobra"
 )
 
+var restart bool
+var maxRetries int
+var sessionFor time.Duration
+var startReason string
+var startConfirm string
+
 // proxyStartCmd represents the proxyStart command
 var proxyStartCmd = &cobra.Command{
-	Use:   "proxyStart",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Use:   "proxyStart <name>",
+	Short: "Start the Cloud SQL Auth Proxy for a saved config",
+	Long: `Start the Cloud SQL Auth Proxy for a saved config in the background.
+
+The gcloud credentials are checked first. When they are missing or expired
+tsunagi offers to run the gcloud login command and retries. With
+--non-interactive, or without a terminal, it fails immediately, printing
+AUTH_REQUIRED and exiting with status 3.
+
+The proxy runs under a background tsunagi process that writes its output,
+with timestamps, to a log file per config shown by tsunagi logs. With
+--restart it also restarts the proxy with exponential backoff when it
+exits, for example after the laptop wakes up from sleep. It gives up after
+--max-retries consecutive failures. proxyStatus shows the restart count and the last exit reason.
+
+Clients connect to the proxy through tsunagi, which forwards the port to it
+and counts the connections. With an idle timeout, set per config with add
+--idle-timeout or for all configs with IDLE_TIMEOUT, tsunagi stops the proxy
+once no client has been connected for that long. proxyStatus shows
+proxies stopped this way as stopped, with the reason.
+
+With --for, or a max session duration set with add --max-session-duration,
+the session is time-boxed: tsunagi warns shortly before the end and then
+stops the proxy. Warnings are logged and passed to NOTIFY_COMMAND, run with
+TSUNAGI_PROXY_NAME and TSUNAGI_MESSAGE set, for example to show a desktop
+notification. tsunagi extend moves the end of the session.
+
+Starting the proxy of a prod config requires typing the config name, or
+passing it with --confirm. Configs saved with add --require-reason also
+require a --reason. Every start and stop is written to the audit log, see
+tsunagi audit.
+
+When tsunagi daemon runs, the proxy is started and supervised by the daemon.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigNames,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("proxyStart called")
+		param, err := getProxyConfig(args[0])
+		if err != nil {
+			fatal(err)
+			return
+		}
+		if cmd.Flags().Changed("for") {
+			param, err = proxy.WithSession(param, sessionFor)
+			if err != nil {
+				fatal(err)
+				return
+			}
+		}
+		reason, err := guardStart(param)
+		if err != nil {
+			fatal(err)
+			return
+		}
+
+		retries := 0
+		if restart {
+			retries = maxRetries
+		}
+		state, err := startProxy(starter(param, sessionFor, retries), interactive())
+		if err != nil {
+			fatal(err)
+			return
+		}
+		recordStart(param, reason)
+		fmt.Printf("Started proxy for %q on 127.0.0.1:%d (pid %d)\n", state.Name, state.Port, state.PID)
+		if !state.ExpiresAt.IsZero() {
+			fmt.Printf("The session ends at %s\n", state.ExpiresAt.Local().Format("15:04:05"))
+		}
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(proxyStartCmd)
+// starter returns the function starting the proxy for the config: through the daemon when it
+// runs, otherwise under a background supervisor restarting it up to retries times.
+// A positive session time-boxes the proxy.
+func starter(param config.ConfigParam, session time.Duration, retries int) func() (*proxy.State, error) {
+	if client := daemonClient(); client != nil {
+		return func() (*proxy.State, error) {
+			state, err := client.Start(param.Name, session, retries)
+			return state, localAuthError(param, err)
+		}
+	}
+	return func() (*proxy.State, error) {
+		p, err := loadProxy(newProxyStateRepository())
+		if err != nil {
+			return nil, err
+		}
+		return p.StartSupervised(param, superviseCommand(param.Name, retries, param.MaxSession()))
+	}
+}
+
+// localAuthError turns the AUTH_REQUIRED error of the daemon into the *utils.AuthError of the
+// config's credentials, so that renewing them is offered as when tsunagi starts the proxy itself.
+func localAuthError(param config.ConfigParam, err error) error {
+	var remote *daemon.RemoteError
+	if !errors.As(err, &remote) || remote.Code() != utils.ErrCodeAuthRequired {
+		return err
+	}
+	var authErr *utils.AuthError
+	if errors.As(utils.CheckGcloudAuth(param.GcloudContext()), &authErr) {
+		return authErr
+	}
+	return err
+}
 
-	// Here you will define your flags and configuration settings.
+// startProxy starts the proxy. When the gcloud credentials are missing or expired
+// and offerLogin is set, it offers to renew them and retries once.
+func startProxy(start func() (*proxy.State, error), offerLogin bool) (*proxy.State, error) {
+	state, err := start()
+	var authErr *utils.AuthError
+	if !errors.As(err, &authErr) || !offerLogin {
+		return state, err
+	}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// proxyStartCmd.PersistentFlags().String("foo", "", "A help for foo")
+	fmt.Fprintln(os.Stderr, "Your gcloud credentials are invalid or have expired.")
+	login := false
+	prompt := &survey.Confirm{
+		Message: "Run `" + authErr.LoginCommand() + "` now?",
+		Default: true,
+	}
+	err = survey.AskOne(prompt, &login, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	if err != nil {
+		return nil, err
+	}
+	if !login {
+		return nil, authErr
+	}
+
+	err = utils.Login(authErr)
+	if err != nil {
+		return nil, err
+	}
+	return start()
+}
+
+// guardStart asks for the confirmation and the reason the config requires before its proxy
+// is started, and returns the reason.
+func guardStart(param config.ConfigParam) (string, error) {
+	if param.IsProd() && startConfirm != param.Name {
+		if startConfirm != "" {
+			return "", errors.New("confirmation does not match the config name")
+		}
+		if !interactive() {
+			return "", fmt.Errorf("%q is a prod config, confirm with --confirm %s", param.Name, param.Name)
+		}
+		var typed string
+		prompt := &survey.Input{
+			Message: fmt.Sprintf("%q connects to prod. Type the config name to confirm", param.Name),
+		}
+		err := survey.AskOne(prompt, &typed, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+		if err != nil {
+			return "", err
+		}
+		if typed != param.Name {
+			return "", errors.New("confirmation does not match the config name")
+		}
+	}
+
+	reason := startReason
+	if reason == "" && param.RequireReason {
+		if !interactive() {
+			return "", fmt.Errorf("a reason is required to start %q, pass --reason", param.Name)
+		}
+		prompt := &survey.Input{
+			Message: "Enter the reason for connecting",
+		}
+		err := survey.AskOne(prompt, &reason, survey.WithValidator(survey.Required), survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+		if err != nil {
+			return "", err
+		}
+	}
+	return reason, nil
+}
+
+// recordStart writes the start of the proxy to the audit log.
+func recordStart(param config.ConfigParam, reason string) {
+	err := newAudit().RecordStart(param, reason)
+	if err != nil {
+		log.Printf("could not write the audit log: %v", err)
+	}
+}
+
+func addStartGuardFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&startReason, "reason", "", "Reason for connecting, written to the audit log")
+	cmd.Flags().StringVar(&startConfirm, "confirm", "", "Name of a prod config, confirming the start without a prompt")
+}
+
+// superviseCommand returns the command running the supervisor for the config.
+func superviseCommand(name string, maxRetries int, session time.Duration) *exec.Cmd {
+	self, err := os.Executable()
+	if err != nil {
+		self = os.Args[0]
+	}
+	args := []string{"supervise", name, "--max-retries", strconv.Itoa(maxRetries)}
+	if session > 0 {
+		args = append(args, "--for", session.String())
+	}
+	return exec.Command(self, args...)
+}
+
+func init() {
+	rootCmd.AddCommand(proxyStartCmd)
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// proxyStartCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	proxyStartCmd.Flags().BoolVar(&restart, "restart", false, "Restart the proxy when it exits")
+	addStartGuardFlags(proxyStartCmd)
+	proxyStartCmd.Flags().DurationVar(&sessionFor, "for", 0, "Stop the proxy after this long, e.g. 30m")
+	proxyStartCmd.Flags().IntVar(&maxRetries, "max-retries", proxy.DefaultRestartPolicy().MaxRetries, "Consecutive restarts before giving up, with --restart")
 }
@@ -1,40 +1,252 @@
 /*
 Copyright © 2025 NAME HERE <EMAIL ADDRESS>
-
 */
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
 
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/health"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
 	"github.com/spf13/cobra"
 )
 
+var statusJSON bool
+var statusPing bool
+
+// proxyStatus is a proxy state with its current status, as printed by proxyStatus.
+type proxyStatus struct {
+	proxy.State
+	Status string
+	Health *health.Report `json:",omitempty"`
+}
+
 // proxyStatusCmd represents the proxyStatus command
 var proxyStatusCmd = &cobra.Command{
-	Use:   "proxyStatus",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Use:   "proxyStatus [name]",
+	Short: "Show the status of proxies started by tsunagi",
+	Long: `Show the status of proxies started by tsunagi.
+
+STATUS tells how far a running proxy was found to work:
+
+  running             the process is alive but its port does not accept connections
+  listening           the port accepts connections and, for configs with a
+                      health check port, the proxy reports itself ready
+  database reachable  the database answered a protocol level ping (--ping,
+                      postgres and mysql only)
+
+Other proxies are restarting (a supervised proxy waiting to be restarted),
+failed (the supervisor gave up), stopped (by tsunagi, for example after the
+idle timeout; LAST EXIT tells why) or exited. RESTARTS and LAST EXIT are
+recorded for proxies started with proxyStart --restart. REMAINING is the
+time left in a time-boxed session.
+
+CONNS shows the open and total client connections, IN and OUT the bytes
+received from and sent to clients and ACTIVITY when a client was last
+active. Health checks connect to the proxy directly and are not counted.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeConfigNames,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("proxyStatus called")
+		states, err := proxyStates()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		params, err := newConfig().List()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		paramsByName := map[string]config.ConfigParam{}
+		for _, param := range params {
+			paramsByName[param.Name] = param
+		}
+
+		statuses := []proxyStatus{}
+		for _, state := range states {
+			if len(args) == 1 && state.Name != args[0] {
+				continue
+			}
+			statuses = append(statuses, proxyStatus{State: state, Status: string(state.Status())})
+		}
+		if len(args) == 1 && len(statuses) == 0 {
+			log.Fatalf("proxy for %q is not running", args[0])
+			return
+		}
+		checkHealth(statuses, paramsByName)
+
+		if statusJSON {
+			out, err := json.MarshalIndent(statuses, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			fmt.Println(string(out))
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPORT\tPID\tSTATUS\tUPTIME\tREMAINING\tRESTARTS\tCONNS\tIN\tOUT\tACTIVITY\tLAST EXIT\tHEALTH")
+		for _, s := range statuses {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				s.Name,
+				s.Port,
+				pidOf(s),
+				s.Status,
+				uptimeOf(s),
+				remainingOf(s),
+				s.Restarts,
+				connsOf(s.State),
+				bytesInOf(s.State),
+				bytesOutOf(s.State),
+				activityOf(s.State),
+				lastExitOf(s.State),
+				healthOf(s),
+			)
+		}
+		w.Flush()
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(proxyStatusCmd)
+// proxyStates returns the states of all proxies, from the daemon when it runs.
+func proxyStates() ([]proxy.State, error) {
+	if client := daemonClient(); client != nil {
+		return client.Status()
+	}
+	p, err := loadProxy(newProxyStateRepository())
+	if err != nil {
+		return nil, err
+	}
+	return p.List()
+}
+
+// checkHealth checks the running proxies concurrently and replaces their status with the level reached.
+func checkHealth(statuses []proxyStatus, params map[string]config.ConfigParam) {
+	checker := health.NewChecker(2 * time.Second)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		s := &statuses[i]
+		if s.Status != string(proxy.PhaseRunning) {
+			continue
+		}
+		param, ok := params[s.Name]
+		if !ok {
+			// The config was removed while the proxy kept running
+			param = config.ConfigParam{Name: s.Name}
+		}
+		param.Port = s.Port
+		if s.ProxyPort != 0 {
+			// Checks through the forwarder would count as client connections
+			param.Port = s.ProxyPort
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report := checker.Check(param, statusPing)
+			s.Health = &report
+			s.Status = string(report.Level())
+		}()
+	}
+	wg.Wait()
+}
+
+func pidOf(s proxyStatus) string {
+	if s.Health == nil || s.PID == 0 {
+		return "-"
+	}
+	return strconv.Itoa(s.PID)
+}
 
-	// Here you will define your flags and configuration settings.
+func uptimeOf(s proxyStatus) string {
+	if s.Health == nil && s.Status != string(proxy.PhaseRestarting) {
+		return "-"
+	}
+	return time.Since(s.StartedAt).Round(time.Second).String()
+}
+
+func remainingOf(s proxyStatus) string {
+	if s.ExpiresAt.IsZero() || uptimeOf(s) == "-" {
+		return "-"
+	}
+	return max(time.Until(s.ExpiresAt), 0).Round(time.Second).String()
+}
+
+func healthOf(s proxyStatus) string {
+	switch {
+	case s.Health == nil:
+		return "-"
+	case s.Health.Error != "":
+		return s.Health.Error
+	default:
+		return "ok"
+	}
+}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// proxyStatusCmd.PersistentFlags().String("foo", "", "A help for foo")
+func connsOf(state proxy.State) string {
+	if state.Connections == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d", state.Connections.Active, state.Connections.Total)
+}
+
+func bytesInOf(state proxy.State) string {
+	if state.Connections == nil {
+		return "-"
+	}
+	return formatBytes(state.Connections.BytesIn)
+}
+
+func bytesOutOf(state proxy.State) string {
+	if state.Connections == nil {
+		return "-"
+	}
+	return formatBytes(state.Connections.BytesOut)
+}
+
+func activityOf(state proxy.State) string {
+	switch {
+	case state.Connections == nil || state.Connections.LastActivity.IsZero():
+		return "-"
+	case state.Connections.Active > 0:
+		return "now"
+	default:
+		return time.Since(state.Connections.LastActivity).Round(time.Second).String() + " ago"
+	}
+}
+
+// formatBytes formats n with a binary unit, such as 1.5MB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func lastExitOf(state proxy.State) string {
+	if state.LastExit == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%s ago)", state.LastExit, time.Since(state.LastExitAt).Round(time.Second))
+}
+
+func init() {
+	rootCmd.AddCommand(proxyStatusCmd)
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// proxyStatusCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	proxyStatusCmd.Flags().BoolVar(&statusJSON, "json", false, "Print the status as JSON")
+	proxyStatusCmd.Flags().BoolVar(&statusPing, "ping", false, "Check that the database answers through the proxy")
 }
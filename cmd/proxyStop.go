@@ -1,40 +1,43 @@
 /*
 Copyright © 2025 NAME HERE <EMAIL ADDRESS>
-
 */
 package cmd
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/spf13/cobra"
 )
 
 // proxyStopCmd represents the proxyStop command
 var proxyStopCmd = &cobra.Command{
-	Use:   "proxyStop",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Use:               "proxyStop <name>",
+	Short:             "Stop the Cloud SQL Auth Proxy of a saved config",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeRunningNames,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("proxyStop called")
+		err := stopProxy(args[0])
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Printf("Stopped proxy for %q\n", args[0])
 	},
 }
 
+// stopProxy stops the proxy for the config, through the daemon when it runs.
+func stopProxy(name string) error {
+	if client := daemonClient(); client != nil {
+		return client.Stop(name)
+	}
+	p, err := loadProxy(newProxyStateRepository())
+	if err != nil {
+		return err
+	}
+	return p.Stop(name)
+}
+
 func init() {
 	rootCmd.AddCommand(proxyStopCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// proxyStopCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// proxyStopCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
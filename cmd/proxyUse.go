@@ -0,0 +1,34 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// proxyUseCmd represents the proxy use command
+var proxyUseCmd = &cobra.Command{
+	Use:   "use <version>",
+	Short: "Select the cloud-sql-proxy version used by proxyStart",
+	Long: `Select an installed cloud-sql-proxy version for proxyStart.
+Use "system" to run the cloud-sql-proxy found on PATH.
+
+  tsunagi proxy use v2.14.0`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newInstaller().Use(args[0])
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Printf("using cloud-sql-proxy %s\n", args[0])
+	},
+}
+
+func init() {
+	proxyCmd.AddCommand(proxyUseCmd)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/spf13/cobra"
+)
+
+// proxyVersionsCmd represents the proxy versions command
+var proxyVersionsCmd = &cobra.Command{
+	Use:   "versions",
+	Short: "List installed cloud-sql-proxy versions",
+	Long: `List installed cloud-sql-proxy versions. The version used by proxyStart
+is marked with "*"; "system" is the cloud-sql-proxy found on PATH.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer := newInstaller()
+		versions, err := installer.Versions()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		current, err := installer.Current()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		for _, version := range append([]string{proxy.SystemVersion}, versions...) {
+			marker := " "
+			if version == current {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, version)
+		}
+	},
+}
+
+func init() {
+	proxyCmd.AddCommand(proxyVersionsCmd)
+}
@@ -22,9 +22,30 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/AlecAivazis/survey/v2"
+	f "github.com/kyoshidaxx/tsunagi/internal/datastore/file"
+	ss "github.com/kyoshidaxx/tsunagi/internal/datastore/secretservice"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/audit"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/daemon"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/logs"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/secret"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -42,6 +63,11 @@ to quickly create a Cobra application.`,
 	// Run: func(cmd *cobra.Command, args []string) { },
 }
 
+var nonInteractive bool
+
+// exitCodeWithCode is the exit status for errors that carry a machine-readable code.
+const exitCodeWithCode = 3
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -51,6 +77,260 @@ func Execute() {
 	}
 }
 
+// interactive reports whether tsunagi may prompt the user.
+func interactive() bool {
+	return !nonInteractive && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// fatal prints err and exits. Errors that carry a machine-readable code are
+// printed as "CODE: message" and exit with exitCodeWithCode so scripts can react to them.
+func fatal(err error) {
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", coded.Code(), err)
+		os.Exit(exitCodeWithCode)
+	}
+	log.Fatal(err)
+}
+
+func newConfig() *config.Config {
+	r := f.NewConfigFileRepository(os.Getenv("CONFIG_FILE_PATH"))
+	return config.NewConfig(r)
+}
+
+func newProxyStateRepository() proxy.Repository {
+	return f.NewProxyStateFileRepository(filepath.Join(filepath.Dir(os.Getenv("CONFIG_FILE_PATH")), "run"))
+}
+
+func newProxy() *proxy.Proxy {
+	return newProxyWith(newProxyStateRepository())
+}
+
+// newProxyWith returns a proxy service that runs the cloud-sql-proxy selected with `proxy use`.
+func newProxyWith(r proxy.Repository) *proxy.Proxy {
+	p, err := loadProxy(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return p
+}
+
+// loadProxy is newProxyWith returning the error instead of exiting, for callers such as the
+// dashboard that must not exit.
+func loadProxy(r proxy.Repository) (*proxy.Proxy, error) {
+	p := proxy.NewProxy(r)
+	p.SetNotifier(notify)
+	p.SetOnStop(recordStop)
+	binary, err := newInstaller().Binary()
+	if err != nil {
+		return nil, err
+	}
+	if binary != "" {
+		p.UseBinary(binary)
+	}
+	return p, nil
+}
+
+// recordStop writes the end of a proxy session to the audit log.
+func recordStop(state proxy.State, status string) {
+	param, err := newConfig().Get(state.Name)
+	if err != nil {
+		// The config may have been removed while the proxy ran
+		param = config.ConfigParam{Name: state.Name, Port: state.Port}
+	}
+	err = newAudit().RecordStop(param, state.StartedAt, status)
+	if err != nil {
+		log.Printf("could not write the audit log: %v", err)
+	}
+}
+
+// notify shows a warning about a proxy on stderr and passes it to NOTIFY_COMMAND when set,
+// with the proxy name and the message in TSUNAGI_PROXY_NAME and TSUNAGI_MESSAGE.
+func notify(name string, message string) {
+	fmt.Fprintf(os.Stderr, "tsunagi: %s\n", message)
+	command := os.Getenv("NOTIFY_COMMAND")
+	if command == "" {
+		return
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), "TSUNAGI_PROXY_NAME="+name, "TSUNAGI_MESSAGE="+message)
+	err := cmd.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tsunagi: NOTIFY_COMMAND failed: %v\n", err)
+	}
+}
+
+// dataDir returns the absolute path of the directory holding the config file and tsunagi's data.
+func dataDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return filepath.Join(homeDir, filepath.Dir(os.Getenv("CONFIG_FILE_PATH")))
+}
+
+// newAudit returns the audit log of proxy sessions, kept next to the config file.
+func newAudit() *audit.Audit {
+	r := f.NewAuditFileRepository(filepath.Join(filepath.Dir(os.Getenv("CONFIG_FILE_PATH")), "audit.log"))
+	return audit.NewAudit(r)
+}
+
+// newLogStore returns the store of the proxies' output. Log files are rotated when they grow
+// beyond LOG_MAX_SIZE_MB megabytes, keeping LOG_KEEP rotated files.
+func newLogStore() *logs.Store {
+	rotation := logs.DefaultRotation()
+	if size := os.Getenv("LOG_MAX_SIZE_MB"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			log.Fatal("LOG_MAX_SIZE_MB is not valid")
+		}
+		rotation.MaxSize = int64(n) * 1024 * 1024
+	}
+	if keep := os.Getenv("LOG_KEEP"); keep != "" {
+		n, err := strconv.Atoi(keep)
+		if err != nil || n < 0 {
+			log.Fatal("LOG_KEEP is not valid")
+		}
+		rotation.Keep = n
+	}
+	return logs.NewStore(filepath.Join(dataDir(), "logs"), rotation)
+}
+
+// socketPath returns the path of the daemon's control socket.
+func socketPath() string {
+	return filepath.Join(dataDir(), "tsunagi.sock")
+}
+
+// daemonClient returns a client of the running daemon, or nil when no daemon runs.
+func daemonClient() *daemon.Client {
+	path := socketPath()
+	if !daemon.SocketAvailable(path) {
+		return nil
+	}
+	return daemon.NewClient(path)
+}
+
+// newInstaller returns the cloud-sql-proxy installer. Releases are downloaded from
+// PROXY_DOWNLOAD_URL and their checksums from the release notes at PROXY_CHECKSUM_URL when
+// they are set.
+func newInstaller() *proxy.Installer {
+	return proxy.NewInstaller(filepath.Join(dataDir(), "bin"), os.Getenv("PROXY_DOWNLOAD_URL"), os.Getenv("PROXY_CHECKSUM_URL"))
+}
+
+// newSecretStore returns the secret store selected by SECRET_BACKEND (file by default).
+func newSecretStore() (secret.Store, error) {
+	switch backend := secret.Backend(os.Getenv("SECRET_BACKEND")); backend {
+	case "", secret.BackendFile:
+		path := filepath.Join(filepath.Dir(os.Getenv("CONFIG_FILE_PATH")), "secrets")
+		return f.NewSecretFileStore(path, askPassphrase), nil
+	case secret.BackendSecretService:
+		if !ss.Available() {
+			return nil, fmt.Errorf("secret backend %q is not available", backend)
+		}
+		return ss.NewSecretServiceStore(), nil
+	default:
+		return nil, fmt.Errorf("secret backend %q is not supported", backend)
+	}
+}
+
+// askPassphrase returns the secret file passphrase from TSUNAGI_PASSPHRASE or asks for it.
+// The prompt is written to stderr so that it does not mix with command output.
+func askPassphrase() (string, error) {
+	if passphrase := os.Getenv("TSUNAGI_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if !interactive() {
+		return "", errors.New("TSUNAGI_PASSPHRASE required without a terminal")
+	}
+	var passphrase string
+	prompt := &survey.Password{
+		Message: "Enter Secret File Passphrase",
+	}
+	err := survey.AskOne(prompt, &passphrase, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	return passphrase, err
+}
+
+// resolvePassword returns the password for the config from its secret source.
+func resolvePassword(param config.ConfigParam) (string, error) {
+	if param.PasswordSecret != "" {
+		return utils.AccessSecretVersion(param.GcloudContext(), param.PasswordSecret)
+	}
+	if param.PasswordKey == "" {
+		return "", fmt.Errorf("no password is stored for %q", param.Name)
+	}
+	store, err := newSecretStore()
+	if err != nil {
+		return "", err
+	}
+	password, err := store.Get(param.PasswordKey)
+	if err != nil {
+		return "", fmt.Errorf("password for %q: %w", param.Name, err)
+	}
+	return password, nil
+}
+
+// getConnectionConfig returns the saved config, looking up the engine of the instance
+// for configs saved without one and the IAM user for IAM authentication without a user.
+func getConnectionConfig(name string) (config.ConfigParam, error) {
+	param, err := newConfig().Get(name)
+	if err != nil {
+		return config.ConfigParam{}, err
+	}
+	if param.Engine == "" {
+		version, err := utils.GetDatabaseVersion(param.GcloudContext(), param.ProjectName, param.InstanceName)
+		if err != nil {
+			return config.ConfigParam{}, err
+		}
+		param.Engine, err = cloud.ParseDatabaseVersion(version)
+		if err != nil {
+			return config.ConfigParam{}, err
+		}
+	}
+	if param.AutoIAMAuthn && param.User == "" {
+		account, err := iamAccount(param)
+		if err != nil {
+			return config.ConfigParam{}, err
+		}
+		param.User = cloud.IAMUser(param.Engine, account)
+	}
+	return param, nil
+}
+
+// getProxyConfig returns the config with the global IDLE_TIMEOUT applied when the config
+// does not set an idle timeout of its own.
+func getProxyConfig(name string) (config.ConfigParam, error) {
+	param, err := newConfig().Get(name)
+	if err != nil || param.IdleTimeout != "" {
+		return param, err
+	}
+	if global := os.Getenv("IDLE_TIMEOUT"); global != "" {
+		d, err := time.ParseDuration(global)
+		if err != nil || d < 0 {
+			return param, errors.New("IDLE_TIMEOUT is not valid")
+		}
+		if d > 0 && d < config.MinIdleTimeout {
+			return param, fmt.Errorf("IDLE_TIMEOUT must be at least %s", config.MinIdleTimeout)
+		}
+		param.IdleTimeout = global
+	}
+	return param, nil
+}
+
+// iamAccount returns the account the proxy authenticates as, which is the
+// impersonated service account when impersonation is configured.
+func iamAccount(param config.ConfigParam) (string, error) {
+	if param.ImpersonateServiceAccount != "" {
+		chain := strings.Split(param.ImpersonateServiceAccount, ",")
+		return chain[len(chain)-1], nil
+	}
+	return utils.GetAccount(param.GcloudContext())
+}
+
 func init() {
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
@@ -59,4 +339,5 @@ func init() {
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "Never prompt, fail with a machine-readable error code instead")
 }
@@ -0,0 +1,68 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/spf13/cobra"
+)
+
+var superviseMaxRetries int
+var superviseFor time.Duration
+
+// superviseCmd represents the supervise command. It is started in the background
+// by proxyStart and exec and is not meant to be run by hand. The proxy's output is
+// written to its log file.
+var superviseCmd = &cobra.Command{
+	Use:    "supervise <name>",
+	Short:  "Run and restart the proxy of a saved config",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		param, err := getProxyConfig(args[0])
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		if superviseFor > 0 {
+			param, err = proxy.WithSession(param, superviseFor)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		policy := proxy.DefaultRestartPolicy()
+		policy.MaxRetries = superviseMaxRetries
+		output, err := newLogStore().Writer(param.Name)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		defer output.Close()
+		err = newProxy().Supervise(ctx, param, policy, output)
+		if err != nil {
+			fmt.Fprintf(output, "tsunagi: %v\n", err)
+			output.Close()
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(superviseCmd)
+
+	superviseCmd.Flags().DurationVar(&superviseFor, "for", 0, "Stop the proxy after this long")
+	superviseCmd.Flags().IntVar(&superviseMaxRetries, "max-retries", proxy.DefaultRestartPolicy().MaxRetries, "Consecutive restarts before giving up")
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/connection"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/logs"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/kyoshidaxx/tsunagi/internal/ui"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// uiCmd represents the ui command
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Show a terminal dashboard of the saved configs and their proxies",
+	Long: `Show a terminal dashboard listing the saved configs with the live status of
+their proxies, refreshed every 2 seconds.
+
+  ↑/↓, j/k  select a config
+  s         start the proxy
+  x         stop the proxy
+  r         restart the proxy
+  l         show or hide the output of the proxy
+  c         copy the connection string, without the password
+  e         filter by environment
+  g         filter by group, set with add --group
+  q         quit
+
+Proxies are started and stopped as with proxyStart and proxyStop, through
+the daemon when it runs. Prod configs require typing the config name and
+configs saved with --require-reason ask for a reason.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := ui.Run(uiService{})
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// uiService drives the dashboard with the functions of the proxy commands.
+type uiService struct{}
+
+func (uiService) Configs() ([]config.ConfigParam, error) {
+	return newConfig().List()
+}
+
+func (uiService) States() ([]proxy.State, error) {
+	return proxyStates()
+}
+
+func (uiService) Start(param config.ConfigParam, reason string) error {
+	param, err := getProxyConfig(param.Name)
+	if err != nil {
+		return err
+	}
+	// The login command cannot run inside the dashboard, so it is shown instead
+	_, err = startProxy(starter(param, 0, 0), false)
+	var authErr *utils.AuthError
+	if errors.As(err, &authErr) {
+		return fmt.Errorf("%s: %w", authErr.Code(), authErr)
+	}
+	if err != nil {
+		return err
+	}
+	recordStart(param, reason)
+	return nil
+}
+
+func (uiService) Stop(name string) error {
+	return stopProxy(name)
+}
+
+func (uiService) DSN(name string) (string, error) {
+	param, err := getConnectionConfig(name)
+	if err != nil {
+		return "", err
+	}
+	return connection.NewInfo(param).DSN(connection.FormatURL)
+}
+
+func (uiService) Logs(ctx context.Context, name string, f func(line string)) error {
+	return newLogStore().Tail(ctx, name, time.Time{}, true, func(line logs.Line) {
+		f(line.String())
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+}
@@ -0,0 +1,75 @@
+package datastore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	a "github.com/kyoshidaxx/tsunagi/internal/domain/audit"
+)
+
+// auditFileRepository appends audit entries to a file as JSON lines.
+type auditFileRepository struct {
+	filePath string
+}
+
+func NewAuditFileRepository(filePath string) a.Repository {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	filePath = filepath.Join(homeDir, filePath)
+	return &auditFileRepository{filePath: filePath}
+}
+
+func (r *auditFileRepository) Append(entry a.Entry) error {
+	err := os.MkdirAll(filepath.Dir(r.filePath), 0700)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(r.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (r *auditFileRepository) FindAll() ([]a.Entry, error) {
+	file, err := os.Open(r.filePath)
+	if os.IsNotExist(err) {
+		return []a.Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []a.Entry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry a.Entry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of %s is not valid: %w", line, r.filePath, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
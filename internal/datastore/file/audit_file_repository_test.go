@@ -0,0 +1,89 @@
+package datastore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	a "github.com/kyoshidaxx/tsunagi/internal/domain/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuditFileRepository(t *testing.T) {
+	repo := NewAuditFileRepository(".tsunagi/audit.log")
+
+	auditRepo, ok := repo.(*auditFileRepository)
+	require.True(t, ok, "Should return auditFileRepository instance")
+
+	homeDir, err := os.UserHomeDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(homeDir, ".tsunagi/audit.log"), auditRepo.filePath)
+}
+
+func TestAuditFileRepository_Append(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "tsunagi", "audit.log")
+	repo := &auditFileRepository{filePath: filePath}
+
+	first := a.Entry{
+		Time:           time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Action:         a.ActionStart,
+		Name:           "billing",
+		ConnectionName: "test-project:asia-northeast1:test-instance",
+		Environment:    "prod",
+		Port:           50000,
+		Reason:         "INC-1234",
+	}
+	second := first
+	second.Reason = ""
+	require.NoError(t, repo.Append(first))
+	require.NoError(t, repo.Append(second))
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 2)
+	var got a.Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &got))
+	assert.Equal(t, first, got)
+	assert.NotContains(t, lines[1], "Reason")
+
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestAuditFileRepository_FindAll(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "audit.log")
+	repo := &auditFileRepository{filePath: filePath}
+
+	entries, err := repo.FindAll()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	start := a.Entry{
+		Time:           time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Action:         a.ActionStart,
+		Name:           "billing",
+		ConnectionName: "test-project:asia-northeast1:test-instance",
+		Account:        "me@example.com",
+		Port:           50000,
+	}
+	stop := start
+	stop.Action = a.ActionStop
+	stop.Duration = "1h0m0s"
+	stop.ExitStatus = "stopped"
+	require.NoError(t, repo.Append(start))
+	require.NoError(t, repo.Append(stop))
+
+	entries, err = repo.FindAll()
+	require.NoError(t, err)
+	assert.Equal(t, []a.Entry{start, stop}, entries)
+
+	require.NoError(t, os.WriteFile(filePath, []byte("{\n"), 0600))
+	_, err = repo.FindAll()
+	assert.ErrorContains(t, err, "line 1 of "+filePath+" is not valid")
+}
@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -39,17 +40,29 @@ func (r *configFileRepository) Save(config c.ConfigParam) error {
 	}
 
 	configParams = append(configParams, config)
-	data, err := json.MarshalIndent(configParams, "", "  ")
+	return r.writeConfigFile(configParams)
+}
+
+func (r *configFileRepository) Update(config c.ConfigParam) error {
+	configParams, err := r.FindAll()
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(r.filePath, data, 0644)
-	if err != nil {
-		return err
+	for i := range configParams {
+		if configParams[i].Name == config.Name {
+			configParams[i] = config
+			return r.writeConfigFile(configParams)
+		}
 	}
+	return fmt.Errorf("config %q not found", config.Name)
+}
 
-	return nil
+func (r *configFileRepository) FindAll() ([]c.ConfigParam, error) {
+	if !r.checkConfigFileExists() {
+		return []c.ConfigParam{}, nil
+	}
+	return r.loadConfigFile()
 }
 
 func (r *configFileRepository) checkConfigFileExists() bool {
@@ -79,6 +92,15 @@ func (r *configFileRepository) loadConfigFile() ([]c.ConfigParam, error) {
 	return configParams, nil
 }
 
+func (r *configFileRepository) writeConfigFile(configParams []c.ConfigParam) error {
+	data, err := json.MarshalIndent(configParams, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.filePath, data, 0644)
+}
+
 func (r *configFileRepository) createConfigFile() error {
 
 	dir := filepath.Dir(r.filePath)
@@ -336,6 +336,60 @@ func TestConfigFileRepository_createConfigFile_ExistingDirectory(t *testing.T) {
 	assert.FileExists(t, testFilePath)
 }
 
+func TestConfigFileRepository_FindAll(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir := t.TempDir()
+	testFilePath := filepath.Join(tempDir, "test-config.json")
+
+	// Create repository
+	repo := &configFileRepository{filePath: testFilePath}
+
+	// Test finding without config file
+	configs, err := repo.FindAll()
+	require.NoError(t, err)
+	assert.Empty(t, configs)
+
+	// Save configurations
+	config1 := c.ConfigParam{Name: "config1", Port: 50001}
+	config2 := c.ConfigParam{Name: "config2", Port: 50002}
+	require.NoError(t, repo.Save(config1))
+	require.NoError(t, repo.Save(config2))
+
+	// Test finding saved configurations
+	configs, err = repo.FindAll()
+	require.NoError(t, err)
+	assert.Equal(t, []c.ConfigParam{config1, config2}, configs)
+}
+
+func TestConfigFileRepository_Update(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir := t.TempDir()
+	testFilePath := filepath.Join(tempDir, "test-config.json")
+
+	// Create repository
+	repo := &configFileRepository{filePath: testFilePath}
+
+	// Test updating without config file
+	err := repo.Update(c.ConfigParam{Name: "config1"})
+	assert.EqualError(t, err, `config "config1" not found`)
+
+	// Save configurations
+	config1 := c.ConfigParam{Name: "config1", Port: 50001}
+	config2 := c.ConfigParam{Name: "config2", Port: 50002}
+	require.NoError(t, repo.Save(config1))
+	require.NoError(t, repo.Save(config2))
+
+	// Update the first configuration
+	updated := c.ConfigParam{Name: "config1", Port: 50003, Database: "app"}
+	err = repo.Update(updated)
+	require.NoError(t, err)
+
+	// Verify the order is kept and only the named configuration changed
+	configs, err := repo.FindAll()
+	require.NoError(t, err)
+	assert.Equal(t, []c.ConfigParam{updated, config2}, configs)
+}
+
 func TestConfigFileRepository_Save_JSONMarshalError(t *testing.T) {
 	// This test is difficult to implement without modifying the code
 	// because json.MarshalIndent rarely fails with valid data
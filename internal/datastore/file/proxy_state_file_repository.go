@@ -0,0 +1,148 @@
+package datastore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	p "github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+)
+
+const (
+	stateFileExt  = ".json"
+	expiryFileExt = ".expiry"
+)
+
+type proxyStateFileRepository struct {
+	dirPath string
+}
+
+func NewProxyStateFileRepository(dirPath string) p.Repository {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	dirPath = filepath.Join(homeDir, dirPath)
+	return &proxyStateFileRepository{dirPath: dirPath}
+}
+
+func (r *proxyStateFileRepository) Save(state p.State) error {
+	err := os.MkdirAll(r.dirPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return r.replaceFile(r.stateFilePath(state.Name), data)
+}
+
+// replaceFile writes data to path at once, as the supervisor saves the state while others read it.
+func (r *proxyStateFileRepository) replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(r.dirPath, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (r *proxyStateFileRepository) Find(name string) (*p.State, error) {
+	data, err := os.ReadFile(r.stateFilePath(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state p.State
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *proxyStateFileRepository) FindAll() ([]p.State, error) {
+	entries, err := os.ReadDir(r.dirPath)
+	if os.IsNotExist(err) {
+		return []p.State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	states := []p.State{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), stateFileExt) {
+			continue
+		}
+		state, err := r.Find(strings.TrimSuffix(entry.Name(), stateFileExt))
+		if err != nil {
+			return nil, err
+		}
+		if state != nil {
+			states = append(states, *state)
+		}
+	}
+	return states, nil
+}
+
+func (r *proxyStateFileRepository) Delete(name string) error {
+	err := r.SaveExpiry(name, time.Time{})
+	if err != nil {
+		return err
+	}
+	err = os.Remove(r.stateFilePath(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *proxyStateFileRepository) SaveExpiry(name string, expiresAt time.Time) error {
+	if expiresAt.IsZero() {
+		err := os.Remove(r.expiryFilePath(name))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	err := os.MkdirAll(r.dirPath, 0700)
+	if err != nil {
+		return err
+	}
+	return r.replaceFile(r.expiryFilePath(name), []byte(expiresAt.Format(time.RFC3339Nano)+"\n"))
+}
+
+func (r *proxyStateFileRepository) FindExpiry(name string) (time.Time, error) {
+	data, err := os.ReadFile(r.expiryFilePath(name))
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+}
+
+func (r *proxyStateFileRepository) stateFilePath(name string) string {
+	return filepath.Join(r.dirPath, name+stateFileExt)
+}
+
+func (r *proxyStateFileRepository) expiryFilePath(name string) string {
+	return filepath.Join(r.dirPath, name+expiryFileExt)
+}
@@ -0,0 +1,153 @@
+package datastore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	p "github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProxyStateFileRepository(t *testing.T) {
+	repo := NewProxyStateFileRepository(".tsunagi/run")
+
+	stateRepo, ok := repo.(*proxyStateFileRepository)
+	require.True(t, ok, "Should return proxyStateFileRepository instance")
+
+	homeDir, err := os.UserHomeDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(homeDir, ".tsunagi/run"), stateRepo.dirPath)
+}
+
+func TestProxyStateFileRepository_SaveFind(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := &proxyStateFileRepository{dirPath: filepath.Join(tempDir, "run")}
+
+	// Test finding a state that was never saved
+	state, err := repo.Find("test-config")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	expected := p.State{
+		Name:      "test-config",
+		PID:       12345,
+		Port:      50000,
+		StartedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	err = repo.Save(expected)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(tempDir, "run", "test-config.json"))
+
+	state, err = repo.Find("test-config")
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, expected, *state)
+}
+
+func TestProxyStateFileRepository_SaveWhileReading(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := &proxyStateFileRepository{dirPath: filepath.Join(tempDir, "run")}
+	require.NoError(t, repo.Save(p.State{Name: "test-config", PID: 1, Port: 50000}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 200 {
+			repo.Save(p.State{Name: "test-config", PID: i, Port: 50000, LastExit: strings.Repeat("x", i*50)})
+		}
+	}()
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		// Readers never see a partly written state
+		_, err := repo.FindAll()
+		require.NoError(t, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(tempDir, "run"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	info, err := entries[0].Info()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestProxyStateFileRepository_Expiry(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := &proxyStateFileRepository{dirPath: filepath.Join(tempDir, "run")}
+
+	expiresAt, err := repo.FindExpiry("test-config")
+	require.NoError(t, err)
+	assert.True(t, expiresAt.IsZero())
+
+	expected := time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC)
+	require.NoError(t, repo.SaveExpiry("test-config", expected))
+	require.NoError(t, repo.Save(p.State{Name: "test-config", PID: 1, Port: 50000}))
+	expiresAt, err = repo.FindExpiry("test-config")
+	require.NoError(t, err)
+	assert.True(t, expected.Equal(expiresAt))
+
+	// The expiry is not a state
+	states, err := repo.FindAll()
+	require.NoError(t, err)
+	assert.Len(t, states, 1)
+
+	require.NoError(t, repo.Delete("test-config"))
+	expiresAt, err = repo.FindExpiry("test-config")
+	require.NoError(t, err)
+	assert.True(t, expiresAt.IsZero())
+}
+
+func TestProxyStateFileRepository_FindAll(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := &proxyStateFileRepository{dirPath: filepath.Join(tempDir, "run")}
+
+	// Test finding without state directory
+	states, err := repo.FindAll()
+	require.NoError(t, err)
+	assert.Empty(t, states)
+
+	require.NoError(t, repo.Save(p.State{Name: "config1", PID: 1, Port: 50001}))
+	require.NoError(t, repo.Save(p.State{Name: "config2", PID: 2, Port: 50002}))
+	// Files other than state files are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "run", "note.txt"), []byte("x"), 0600))
+
+	states, err = repo.FindAll()
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	assert.Equal(t, "config1", states[0].Name)
+	assert.Equal(t, "config2", states[1].Name)
+}
+
+func TestProxyStateFileRepository_Delete(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := &proxyStateFileRepository{dirPath: tempDir}
+
+	require.NoError(t, repo.Save(p.State{Name: "test-config", PID: 1, Port: 50000}))
+
+	err := repo.Delete("test-config")
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(tempDir, "test-config.json"))
+
+	// Deleting a missing state is not an error
+	err = repo.Delete("test-config")
+	assert.NoError(t, err)
+}
+
+func TestProxyStateFileRepository_Find_InvalidJSON(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := &proxyStateFileRepository{dirPath: tempDir}
+
+	err := os.WriteFile(filepath.Join(tempDir, "broken.json"), []byte("invalid json content"), 0600)
+	require.NoError(t, err)
+
+	_, err = repo.Find("broken")
+	assert.Error(t, err)
+}
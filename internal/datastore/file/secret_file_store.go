@@ -0,0 +1,177 @@
+package datastore
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	s "github.com/kyoshidaxx/tsunagi/internal/domain/secret"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const secretFileVersion = 1
+
+// secretFile is the on-disk form of the encrypted secrets.
+// Data is the JSON encoded map of secrets sealed with NaCl secretbox,
+// using a key derived from the passphrase with scrypt.
+type secretFile struct {
+	Version int
+	Salt    []byte
+	Nonce   []byte
+	Data    []byte
+}
+
+type secretFileStore struct {
+	filePath   string
+	passphrase func() (string, error)
+	key        *[32]byte
+	salt       []byte
+}
+
+// NewSecretFileStore returns a store that keeps secrets in a passphrase encrypted file.
+// passphrase is called at most once, when the file is first read or written.
+func NewSecretFileStore(filePath string, passphrase func() (string, error)) s.Store {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	filePath = filepath.Join(homeDir, filePath)
+	return &secretFileStore{filePath: filePath, passphrase: passphrase}
+}
+
+func (r *secretFileStore) Get(key string) (string, error) {
+	secrets, err := r.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[key]
+	if !ok {
+		return "", s.ErrNotFound
+	}
+	return value, nil
+}
+
+func (r *secretFileStore) Set(key string, value string) error {
+	secrets, err := r.load()
+	if err != nil {
+		return err
+	}
+	secrets[key] = value
+	return r.save(secrets)
+}
+
+func (r *secretFileStore) Delete(key string) error {
+	secrets, err := r.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[key]; !ok {
+		return s.ErrNotFound
+	}
+	delete(secrets, key)
+	return r.save(secrets)
+}
+
+func (r *secretFileStore) load() (map[string]string, error) {
+	data, err := os.ReadFile(r.filePath)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file secretFile
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+	if file.Version != secretFileVersion || len(file.Nonce) != 24 {
+		return nil, errors.New("secret file format is not supported")
+	}
+
+	key, err := r.deriveKey(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], file.Nonce)
+	plain, ok := secretbox.Open(nil, file.Data, &nonce, key)
+	if !ok {
+		return nil, errors.New("secret file could not be decrypted, the passphrase may be wrong")
+	}
+
+	secrets := map[string]string{}
+	err = json.Unmarshal(plain, &secrets)
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (r *secretFileStore) save(secrets map[string]string) error {
+	if r.salt == nil {
+		salt := make([]byte, 16)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return err
+		}
+		r.salt = salt
+	}
+	key, err := r.deriveKey(r.salt)
+	if err != nil {
+		return err
+	}
+
+	var nonce [24]byte
+	_, err = rand.Read(nonce[:])
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(secretFile{
+		Version: secretFileVersion,
+		Salt:    r.salt,
+		Nonce:   nonce[:],
+		Data:    secretbox.Seal(nil, plain, &nonce, key),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(r.filePath), 0700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.filePath, data, 0600)
+}
+
+// deriveKey derives the encryption key for salt, asking for the passphrase only once.
+func (r *secretFileStore) deriveKey(salt []byte) (*[32]byte, error) {
+	if r.key != nil && string(r.salt) == string(salt) {
+		return r.key, nil
+	}
+	passphrase, err := r.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase is required")
+	}
+	derived, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	r.passphrase = func() (string, error) { return passphrase, nil }
+	r.key = new([32]byte)
+	copy(r.key[:], derived)
+	r.salt = salt
+	return r.key, nil
+}
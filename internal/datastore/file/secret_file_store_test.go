@@ -0,0 +1,122 @@
+package datastore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	s "github.com/kyoshidaxx/tsunagi/internal/domain/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSecretFileStore(filePath string, passphrase string) *secretFileStore {
+	return &secretFileStore{
+		filePath:   filePath,
+		passphrase: func() (string, error) { return passphrase, nil },
+	}
+}
+
+func TestNewSecretFileStore(t *testing.T) {
+	store := NewSecretFileStore(".tsunagi/secrets", nil)
+
+	fileStore, ok := store.(*secretFileStore)
+	require.True(t, ok, "Should return secretFileStore instance")
+
+	homeDir, err := os.UserHomeDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(homeDir, ".tsunagi/secrets"), fileStore.filePath)
+}
+
+func TestSecretFileStore_SetGet(t *testing.T) {
+	tempDir := t.TempDir()
+	testFilePath := filepath.Join(tempDir, "nested", "secrets")
+
+	store := newTestSecretFileStore(testFilePath, "passphrase")
+
+	// Test getting from a missing file
+	_, err := store.Get("billing")
+	assert.ErrorIs(t, err, s.ErrNotFound)
+
+	err = store.Set("billing", "p@ssw0rd")
+	require.NoError(t, err)
+
+	// Verify the file is private and does not contain the secret in plain text
+	info, err := os.Stat(testFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	data, err := os.ReadFile(testFilePath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "p@ssw0rd")
+
+	// Verify a new store with the same passphrase can read it
+	value, err := newTestSecretFileStore(testFilePath, "passphrase").Get("billing")
+	require.NoError(t, err)
+	assert.Equal(t, "p@ssw0rd", value)
+}
+
+func TestSecretFileStore_WrongPassphrase(t *testing.T) {
+	tempDir := t.TempDir()
+	testFilePath := filepath.Join(tempDir, "secrets")
+
+	require.NoError(t, newTestSecretFileStore(testFilePath, "passphrase").Set("billing", "p@ssw0rd"))
+
+	_, err := newTestSecretFileStore(testFilePath, "wrong").Get("billing")
+	assert.EqualError(t, err, "secret file could not be decrypted, the passphrase may be wrong")
+}
+
+func TestSecretFileStore_EmptyPassphrase(t *testing.T) {
+	tempDir := t.TempDir()
+	store := newTestSecretFileStore(filepath.Join(tempDir, "secrets"), "")
+
+	err := store.Set("billing", "p@ssw0rd")
+	assert.EqualError(t, err, "passphrase is required")
+}
+
+func TestSecretFileStore_PassphraseAskedOnce(t *testing.T) {
+	tempDir := t.TempDir()
+	calls := 0
+	store := &secretFileStore{
+		filePath: filepath.Join(tempDir, "secrets"),
+		passphrase: func() (string, error) {
+			calls++
+			return "passphrase", nil
+		},
+	}
+
+	require.NoError(t, store.Set("billing", "one"))
+	require.NoError(t, store.Set("orders", "two"))
+	value, err := store.Get("orders")
+	require.NoError(t, err)
+	assert.Equal(t, "two", value)
+	assert.Equal(t, 1, calls)
+}
+
+func TestSecretFileStore_Delete(t *testing.T) {
+	tempDir := t.TempDir()
+	store := newTestSecretFileStore(filepath.Join(tempDir, "secrets"), "passphrase")
+
+	require.NoError(t, store.Set("billing", "one"))
+	require.NoError(t, store.Set("orders", "two"))
+
+	err := store.Delete("billing")
+	require.NoError(t, err)
+
+	_, err = store.Get("billing")
+	assert.ErrorIs(t, err, s.ErrNotFound)
+	value, err := store.Get("orders")
+	require.NoError(t, err)
+	assert.Equal(t, "two", value)
+
+	err = store.Delete("billing")
+	assert.ErrorIs(t, err, s.ErrNotFound)
+}
+
+func TestSecretFileStore_InvalidFile(t *testing.T) {
+	tempDir := t.TempDir()
+	testFilePath := filepath.Join(tempDir, "secrets")
+	require.NoError(t, os.WriteFile(testFilePath, []byte(`{"Version": 2}`), 0600))
+
+	_, err := newTestSecretFileStore(testFilePath, "passphrase").Get("billing")
+	assert.EqualError(t, err, "secret file format is not supported")
+}
@@ -0,0 +1,87 @@
+package datastore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	s "github.com/kyoshidaxx/tsunagi/internal/domain/secret"
+)
+
+const (
+	secretTool       = "secret-tool"
+	serviceAttribute = "service"
+	serviceName      = "tsunagi"
+	keyAttribute     = "key"
+)
+
+// secretServiceStore keeps secrets in the freedesktop Secret Service (GNOME Keyring, KWallet)
+// through the secret-tool command.
+type secretServiceStore struct{}
+
+func NewSecretServiceStore() s.Store {
+	return &secretServiceStore{}
+}
+
+// Available reports whether the Secret Service can be used on this machine.
+func Available() bool {
+	if runtime.GOOS != "linux" || os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return false
+	}
+	_, err := exec.LookPath(secretTool)
+	return err == nil
+}
+
+func (r *secretServiceStore) Get(key string) (string, error) {
+	cmd := exec.Command(secretTool, "lookup", serviceAttribute, serviceName, keyAttribute, key)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// secret-tool exits with 1 without a message when nothing matches
+		if _, ok := err.(*exec.ExitError); ok && stderr.Len() == 0 {
+			return "", s.ErrNotFound
+		}
+		return "", secretToolError(err, stderr.String())
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (r *secretServiceStore) Set(key string, value string) error {
+	cmd := exec.Command(secretTool, "store", "--label", "tsunagi: "+key, serviceAttribute, serviceName, keyAttribute, key)
+	cmd.Stdin = strings.NewReader(value)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return secretToolError(err, stderr.String())
+	}
+	return nil
+}
+
+func (r *secretServiceStore) Delete(key string) error {
+	_, err := r.Get(key)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(secretTool, "clear", serviceAttribute, serviceName, keyAttribute, key)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return secretToolError(err, stderr.String())
+	}
+	return nil
+}
+
+func secretToolError(err error, stderr string) error {
+	stderr = strings.TrimSpace(stderr)
+	if len(stderr) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s: %w", secretTool, errors.New(stderr))
+}
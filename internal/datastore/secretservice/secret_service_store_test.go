@@ -0,0 +1,72 @@
+package datastore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	s "github.com/kyoshidaxx/tsunagi/internal/domain/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecretTool is a secret-tool replacement that keeps each secret in a file named after its key.
+const fakeSecretTool = `#!/bin/sh
+dir="$FAKE_SECRET_DIR"
+case "$1" in
+lookup) [ -f "$dir/$5" ] || exit 1; cat "$dir/$5";;
+store) cat > "$dir/$7";;
+clear) rm -f "$dir/$5";;
+esac
+`
+
+func setupFakeSecretTool(t *testing.T) string {
+	t.Helper()
+	binDir := t.TempDir()
+	secretDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(binDir, "secret-tool"), []byte(fakeSecretTool), 0755)
+	require.NoError(t, err)
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_SECRET_DIR", secretDir)
+	return secretDir
+}
+
+func TestAvailable(t *testing.T) {
+	setupFakeSecretTool(t)
+
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+	assert.False(t, Available())
+}
+
+func TestSecretServiceStore(t *testing.T) {
+	secretDir := setupFakeSecretTool(t)
+	store := NewSecretServiceStore()
+
+	_, err := store.Get("billing")
+	assert.ErrorIs(t, err, s.ErrNotFound)
+
+	err = store.Set("billing", "p@ssw0rd")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(secretDir, "billing"))
+
+	value, err := store.Get("billing")
+	require.NoError(t, err)
+	assert.Equal(t, "p@ssw0rd", value)
+
+	err = store.Delete("billing")
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(secretDir, "billing"))
+
+	err = store.Delete("billing")
+	assert.ErrorIs(t, err, s.ErrNotFound)
+}
+
+func TestSecretServiceStore_Error(t *testing.T) {
+	binDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(binDir, "secret-tool"), []byte("#!/bin/sh\necho 'Cannot autolaunch D-Bus' >&2\nexit 1\n"), 0755)
+	require.NoError(t, err)
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	_, err = NewSecretServiceStore().Get("billing")
+	assert.EqualError(t, err, "secret-tool: Cannot autolaunch D-Bus")
+}
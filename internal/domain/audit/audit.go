@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
+)
+
+type Action string
+
+const (
+	ActionStart Action = "start"
+	ActionStop  Action = "stop"
+)
+
+// Entry is a line of the audit log.
+type Entry struct {
+	Time           time.Time
+	Action         Action
+	Name           string
+	ConnectionName string
+	Environment    config.Environment `json:",omitempty"`
+	Account        string             `json:",omitempty"`
+	Port           int
+	Reason         string `json:",omitempty"`
+	// Duration is how long the session lasted, for stop entries.
+	Duration string `json:",omitempty"`
+	// ExitStatus tells how the session ended, for stop entries.
+	ExitStatus string `json:",omitempty"`
+}
+
+// Filter selects audit entries. Zero fields match every entry.
+type Filter struct {
+	Since time.Time
+	Name  string
+}
+
+func (f Filter) match(entry Entry) bool {
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	return f.Name == "" || entry.Name == f.Name
+}
+
+type Audit struct {
+	r       Repository
+	now     func() time.Time
+	account func(gc utils.GcloudContext) (string, error)
+}
+
+func NewAudit(r Repository) *Audit {
+	return &Audit{r: r, now: time.Now, account: utils.GetAccount}
+}
+
+// RecordStart records that the proxy for the config was started for reason.
+func (a *Audit) RecordStart(param config.ConfigParam, reason string) error {
+	entry := a.entry(param, ActionStart)
+	entry.Reason = reason
+	return a.r.Append(entry)
+}
+
+// RecordStop records that the session of the proxy for the config started at startedAt
+// ended, with status telling how.
+func (a *Audit) RecordStop(param config.ConfigParam, startedAt time.Time, status string) error {
+	entry := a.entry(param, ActionStop)
+	if !startedAt.IsZero() {
+		entry.Duration = entry.Time.Sub(startedAt).Round(time.Second).String()
+	}
+	entry.ExitStatus = status
+	return a.r.Append(entry)
+}
+
+func (a *Audit) entry(param config.ConfigParam, action Action) Entry {
+	entry := Entry{
+		Time:        a.now(),
+		Action:      action,
+		Name:        param.Name,
+		Environment: param.Environment,
+		Port:        param.Port,
+	}
+	if param.InstanceName != "" {
+		entry.ConnectionName = cloud.ConnectionName(param.ProjectName, param.Region, param.InstanceName)
+	}
+	// The account is best effort, a session is recorded even when gcloud cannot tell it
+	entry.Account, _ = a.account(param.GcloudContext())
+	return entry
+}
+
+// Find returns the entries matching the filter, oldest first.
+func (a *Audit) Find(filter Filter) ([]Entry, error) {
+	entries, err := a.r.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	var found []Entry
+	for _, entry := range entries {
+		if filter.match(entry) {
+			found = append(found, entry)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockRepository is an in-memory implementation of the Repository interface for testing
+type mockRepository struct {
+	entries []Entry
+}
+
+func (m *mockRepository) Append(entry Entry) error {
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *mockRepository) FindAll() ([]Entry, error) {
+	return m.entries, nil
+}
+
+func newTestAudit(now time.Time) (*Audit, *mockRepository) {
+	repo := &mockRepository{}
+	a := NewAudit(repo)
+	a.now = func() time.Time { return now }
+	a.account = func(gc utils.GcloudContext) (string, error) {
+		if gc.Configuration == "client-a" {
+			return "", errors.New("no active gcloud account")
+		}
+		return "me@example.com", nil
+	}
+	return a, repo
+}
+
+func testParam() config.ConfigParam {
+	return config.ConfigParam{
+		Name:         "billing",
+		Port:         50000,
+		ProjectName:  "test-project",
+		Region:       "asia-northeast1",
+		InstanceName: "test-instance",
+		Environment:  config.EnvironmentProd,
+	}
+}
+
+func TestAudit_RecordStart(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	a, repo := newTestAudit(now)
+
+	err := a.RecordStart(testParam(), "INC-1234")
+
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{{
+		Time:           now,
+		Action:         ActionStart,
+		Name:           "billing",
+		ConnectionName: "test-project:asia-northeast1:test-instance",
+		Environment:    config.EnvironmentProd,
+		Account:        "me@example.com",
+		Port:           50000,
+		Reason:         "INC-1234",
+	}}, repo.entries)
+}
+
+func TestAudit_RecordStop(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	a, repo := newTestAudit(now)
+	param := testParam()
+	param.GcloudConfiguration = "client-a"
+
+	err := a.RecordStop(param, now.Add(-90*time.Minute), "session expired after 1h30m0s")
+
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{{
+		Time:           now,
+		Action:         ActionStop,
+		Name:           "billing",
+		ConnectionName: "test-project:asia-northeast1:test-instance",
+		Environment:    config.EnvironmentProd,
+		Port:           50000,
+		Duration:       "1h30m0s",
+		ExitStatus:     "session expired after 1h30m0s",
+	}}, repo.entries)
+}
+
+func TestAudit_Find(t *testing.T) {
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	a, repo := newTestAudit(now)
+	repo.entries = []Entry{
+		{Time: now.Add(-8 * 24 * time.Hour), Action: ActionStart, Name: "billing"},
+		{Time: now.Add(-2 * time.Hour), Action: ActionStart, Name: "orders"},
+		{Time: now.Add(-time.Hour), Action: ActionStart, Name: "billing"},
+	}
+
+	entries, err := a.Find(Filter{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	entries, err = a.Find(Filter{Since: now.Add(-7 * 24 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, repo.entries[1:], entries)
+
+	entries, err = a.Find(Filter{Since: now.Add(-7 * 24 * time.Hour), Name: "billing"})
+	require.NoError(t, err)
+	assert.Equal(t, repo.entries[2:], entries)
+}
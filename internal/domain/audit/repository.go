@@ -0,0 +1,7 @@
+package audit
+
+type Repository interface {
+	Append(entry Entry) error
+	// FindAll returns all entries in the order they were appended.
+	FindAll() ([]Entry, error)
+}
@@ -1 +1,74 @@
 package cloud
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Engine string
+
+const (
+	EnginePostgres  Engine = "postgres"
+	EngineMySQL     Engine = "mysql"
+	EngineSQLServer Engine = "sqlserver"
+)
+
+type IPType string
+
+const (
+	IPTypePublic  IPType = "public"
+	IPTypePrivate IPType = "private"
+	IPTypePSC     IPType = "psc"
+)
+
+func GetEngineList() []Engine {
+	return []Engine{
+		EnginePostgres,
+		EngineMySQL,
+		EngineSQLServer,
+	}
+}
+
+func GetIPTypeList() []IPType {
+	return []IPType{
+		IPTypePublic,
+		IPTypePrivate,
+		IPTypePSC,
+	}
+}
+
+// ConnectionName returns the instance connection name used by the Cloud SQL Auth Proxy.
+func ConnectionName(projectName, region, instanceName string) string {
+	return fmt.Sprintf("%s:%s:%s", projectName, region, instanceName)
+}
+
+// ParseDatabaseVersion converts a Cloud SQL database version (e.g. POSTGRES_15, MYSQL_8_0) to its engine.
+func ParseDatabaseVersion(version string) (Engine, error) {
+	version = strings.ToUpper(strings.TrimSpace(version))
+	switch {
+	case strings.HasPrefix(version, "POSTGRES"):
+		return EnginePostgres, nil
+	case strings.HasPrefix(version, "MYSQL"):
+		return EngineMySQL, nil
+	case strings.HasPrefix(version, "SQLSERVER"):
+		return EngineSQLServer, nil
+	}
+	return "", errors.New("database version is not supported")
+}
+
+// SupportsIAMAuthn reports whether the engine supports IAM database authentication.
+func SupportsIAMAuthn(engine Engine) bool {
+	return engine == EnginePostgres || engine == EngineMySQL
+}
+
+// IAMUser returns the database user name Cloud SQL creates for an IAM account.
+// PostgreSQL uses the email with the .gserviceaccount.com suffix trimmed for service accounts,
+// MySQL uses the part of the email before the @.
+func IAMUser(engine Engine, account string) string {
+	if engine == EngineMySQL {
+		user, _, _ := strings.Cut(account, "@")
+		return user
+	}
+	return strings.TrimSuffix(account, ".gserviceaccount.com")
+}
@@ -0,0 +1,70 @@
+package cloud
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionName(t *testing.T) {
+	assert.Equal(t, "test-project:asia-northeast1:test-instance", ConnectionName("test-project", "asia-northeast1", "test-instance"))
+}
+
+func TestGetEngineList(t *testing.T) {
+	assert.Equal(t, []Engine{EnginePostgres, EngineMySQL, EngineSQLServer}, GetEngineList())
+}
+
+func TestGetIPTypeList(t *testing.T) {
+	assert.Equal(t, []IPType{IPTypePublic, IPTypePrivate, IPTypePSC}, GetIPTypeList())
+}
+
+func TestParseDatabaseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    Engine
+	}{
+		{version: "POSTGRES_15", want: EnginePostgres},
+		{version: "MYSQL_8_0", want: EngineMySQL},
+		{version: "SQLSERVER_2019_STANDARD", want: EngineSQLServer},
+		{version: "postgres_16\n", want: EnginePostgres},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			engine, err := ParseDatabaseVersion(tt.version)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, engine)
+		})
+	}
+
+	_, err := ParseDatabaseVersion("ORACLE_19")
+	assert.EqualError(t, err, "database version is not supported")
+}
+
+func TestSupportsIAMAuthn(t *testing.T) {
+	assert.True(t, SupportsIAMAuthn(EnginePostgres))
+	assert.True(t, SupportsIAMAuthn(EngineMySQL))
+	assert.False(t, SupportsIAMAuthn(EngineSQLServer))
+	assert.False(t, SupportsIAMAuthn(""))
+}
+
+func TestIAMUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		engine  Engine
+		account string
+		want    string
+	}{
+		{name: "postgres user", engine: EnginePostgres, account: "dev@example.com", want: "dev@example.com"},
+		{name: "postgres service account", engine: EnginePostgres, account: "app@test-project.iam.gserviceaccount.com", want: "app@test-project.iam"},
+		{name: "mysql user", engine: EngineMySQL, account: "dev@example.com", want: "dev"},
+		{name: "mysql service account", engine: EngineMySQL, account: "app@test-project.iam.gserviceaccount.com", want: "app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IAMUser(tt.engine, tt.account))
+		})
+	}
+}
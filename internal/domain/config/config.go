@@ -2,23 +2,107 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"slices"
+	"strings"
+	"time"
 
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
 	"github.com/kyoshidaxx/tsunagi/internal/utils"
 )
 
+type Environment string
+
+const (
+	EnvironmentDev     Environment = "dev"
+	EnvironmentStaging Environment = "staging"
+	EnvironmentProd    Environment = "prod"
+)
+
+func GetEnvironmentList() []Environment {
+	return []Environment{
+		EnvironmentDev,
+		EnvironmentStaging,
+		EnvironmentProd,
+	}
+}
+
 type ConfigParam struct {
-	Name         string
-	Port         int
-	ProjectName  string
-	Region       string
-	InstanceName string
+	Name           string
+	Port           int
+	ProjectName    string
+	Region         string
+	InstanceName   string
+	Engine         cloud.Engine `json:",omitempty"`
+	Database       string       `json:",omitempty"`
+	User           string       `json:",omitempty"`
+	PasswordKey    string       `json:",omitempty"` // key of the password in the secret store
+	PasswordSecret string       `json:",omitempty"` // Secret Manager version holding the password
+	AutoIAMAuthn   bool         `json:",omitempty"` // IAM database authentication through the proxy
+	IPType         cloud.IPType `json:",omitempty"` // public when empty
+	// ImpersonateServiceAccount is a comma separated delegation chain whose last entry is the target.
+	ImpersonateServiceAccount string      `json:",omitempty"`
+	GcloudConfiguration       string      `json:",omitempty"` // gcloud configuration to run gcloud and the proxy with
+	CredentialsFile           string      `json:",omitempty"` // service account key used instead of the ADC file
+	HealthCheckPort           int         `json:",omitempty"` // port of the proxy's HTTP health check endpoints
+	IdleTimeout               string      `json:",omitempty"` // duration without connections after which the proxy is stopped, "0" for never
+	MaxSessionDuration        string      `json:",omitempty"` // duration after which the proxy is stopped, unless extended
+	Environment               Environment `json:",omitempty"`
+	RequireReason             bool        `json:",omitempty"` // starting the proxy requires a reason for the audit log
+	Group                     string      `json:",omitempty"` // group the config is listed under, such as a team or client
+}
+
+// MinIdleTimeout is the shortest idle timeout a config can set.
+const MinIdleTimeout = time.Second
+
+// IdleTimeoutDuration returns the idle timeout, or 0 when the proxy is never stopped for being idle.
+func (p ConfigParam) IdleTimeoutDuration() time.Duration {
+	d, err := time.ParseDuration(p.IdleTimeout)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// MinSessionDuration is the shortest session a proxy can be time-boxed to.
+const MinSessionDuration = time.Second
+
+// MaxSession returns the maximum session duration, or 0 when sessions are not time-boxed.
+func (p ConfigParam) MaxSession() time.Duration {
+	d, err := time.ParseDuration(p.MaxSessionDuration)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// IsProd reports whether the config connects to a production environment.
+func (p ConfigParam) IsProd() bool {
+	return p.Environment == EnvironmentProd
+}
+
+// HasPassword reports whether a password source is configured.
+func (p ConfigParam) HasPassword() bool {
+	return len(p.PasswordKey) > 0 || len(p.PasswordSecret) > 0
+}
+
+// GcloudContext returns the gcloud context the config's gcloud commands and proxy run in.
+func (p ConfigParam) GcloudContext() utils.GcloudContext {
+	return utils.GcloudContext{
+		Configuration:   p.GcloudConfiguration,
+		CredentialsFile: p.CredentialsFile,
+	}
 }
 
 type Config struct {
 	r Repository
 }
 
+var gcloudConfigurationPattern = regexp.MustCompile(`^[a-z][-a-z0-9]*$`)
+
 const (
 	ephemelalPortFrom = 49152
 	ephemelalPortTo   = 65535
@@ -29,9 +113,32 @@ func NewConfig(r Repository) *Config {
 }
 
 func (c *Config) Add(param ConfigParam) error {
+	err := validate(param)
+	if err != nil {
+		return err
+	}
+	// todo Nameの重複チェック
+
+	return c.r.Save(param)
+}
+
+// Update replaces the saved config that has the same name.
+func (c *Config) Update(param ConfigParam) error {
+	err := validate(param)
+	if err != nil {
+		return err
+	}
+	return c.r.Update(param)
+}
+
+func validate(param ConfigParam) error {
 	if len(param.Name) == 0 {
 		return errors.New("name is required")
 	}
+	// The name is part of the paths of the proxy's state and log files
+	if strings.ContainsAny(param.Name, `/\`) || strings.Contains(param.Name, "..") {
+		return errors.New("name is not valid")
+	}
 	if param.Port < ephemelalPortFrom || param.Port > ephemelalPortTo {
 		return errors.New("port is out of range")
 	}
@@ -48,7 +155,96 @@ func (c *Config) Add(param ConfigParam) error {
 	if len(param.InstanceName) == 0 {
 		return errors.New("instance name is required")
 	}
-	// todo Nameの重複チェック
+	if len(param.Engine) > 0 && !slices.Contains(cloud.GetEngineList(), param.Engine) {
+		return errors.New("engine is not valid")
+	}
+	if (len(param.Database) > 0 || len(param.User) > 0) && len(param.Engine) == 0 {
+		return errors.New("engine is required when database or user is set")
+	}
+	if strings.ContainsAny(param.Database, " \t\r\n/") {
+		return errors.New("database name is not valid")
+	}
+	if strings.ContainsAny(param.User, " \t\r\n") {
+		return errors.New("user is not valid")
+	}
+	if len(param.PasswordSecret) > 0 && !utils.ValidSecretVersionName(param.PasswordSecret) {
+		return errors.New("password secret is not valid")
+	}
+	if len(param.PasswordKey) > 0 && len(param.PasswordSecret) > 0 {
+		return errors.New("password key and password secret cannot be used together")
+	}
+	if len(param.IPType) > 0 && !slices.Contains(cloud.GetIPTypeList(), param.IPType) {
+		return errors.New("ip type is not valid")
+	}
+	if len(param.ImpersonateServiceAccount) > 0 {
+		for _, account := range strings.Split(param.ImpersonateServiceAccount, ",") {
+			if !strings.HasSuffix(account, ".gserviceaccount.com") || !strings.Contains(account, "@") {
+				return errors.New("impersonate service account is not valid")
+			}
+		}
+	}
+	if len(param.GcloudConfiguration) > 0 && !gcloudConfigurationPattern.MatchString(param.GcloudConfiguration) {
+		return errors.New("gcloud configuration is not valid")
+	}
+	if len(param.CredentialsFile) > 0 {
+		if !filepath.IsAbs(param.CredentialsFile) {
+			return errors.New("credentials file must be an absolute path")
+		}
+		if _, err := os.Stat(param.CredentialsFile); err != nil {
+			return errors.New("credentials file does not exist")
+		}
+	}
+	if param.HealthCheckPort != 0 && (param.HealthCheckPort < 1 || param.HealthCheckPort > 65535 || param.HealthCheckPort == param.Port) {
+		return errors.New("health check port is not valid")
+	}
+	if len(param.IdleTimeout) > 0 {
+		d, err := time.ParseDuration(param.IdleTimeout)
+		if err != nil || d < 0 {
+			return errors.New("idle timeout is not valid")
+		}
+		if d > 0 && d < MinIdleTimeout {
+			return fmt.Errorf("idle timeout must be at least %s", MinIdleTimeout)
+		}
+	}
+	if len(param.Environment) > 0 && !slices.Contains(GetEnvironmentList(), param.Environment) {
+		return errors.New("environment is not valid")
+	}
+	if len(param.MaxSessionDuration) > 0 {
+		d, err := time.ParseDuration(param.MaxSessionDuration)
+		if err != nil || d <= 0 {
+			return errors.New("max session duration is not valid")
+		}
+		if d < MinSessionDuration {
+			return fmt.Errorf("max session duration must be at least %s", MinSessionDuration)
+		}
+	}
+	if param.AutoIAMAuthn {
+		if len(param.Engine) == 0 {
+			return errors.New("engine is required when IAM authentication is enabled")
+		}
+		if !cloud.SupportsIAMAuthn(param.Engine) {
+			return errors.New("engine does not support IAM authentication")
+		}
+		if param.HasPassword() {
+			return errors.New("password cannot be used with IAM authentication")
+		}
+	}
+	return nil
+}
 
-	return c.r.Save(param)
+func (c *Config) List() ([]ConfigParam, error) {
+	return c.r.FindAll()
+}
+
+func (c *Config) Get(name string) (ConfigParam, error) {
+	params, err := c.r.FindAll()
+	if err != nil {
+		return ConfigParam{}, err
+	}
+	for _, param := range params {
+		if param.Name == name {
+			return param, nil
+		}
+	}
+	return ConfigParam{}, fmt.Errorf("config %q not found", name)
 }
@@ -2,7 +2,10 @@ package config
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -10,9 +13,14 @@ import (
 
 // mockRepository is a mock implementation of the Repository interface for testing
 type mockRepository struct {
-	saveCalled bool
-	saveParam  ConfigParam
-	saveError  error
+	saveCalled   bool
+	saveParam    ConfigParam
+	saveError    error
+	findAllParam []ConfigParam
+	findAllError error
+	updateCalled bool
+	updateParam  ConfigParam
+	updateError  error
 }
 
 func (m *mockRepository) Save(config ConfigParam) error {
@@ -21,6 +29,16 @@ func (m *mockRepository) Save(config ConfigParam) error {
 	return m.saveError
 }
 
+func (m *mockRepository) Update(config ConfigParam) error {
+	m.updateCalled = true
+	m.updateParam = config
+	return m.updateError
+}
+
+func (m *mockRepository) FindAll() ([]ConfigParam, error) {
+	return m.findAllParam, m.findAllError
+}
+
 func (m *mockRepository) reset() {
 	m.saveCalled = false
 	m.saveParam = ConfigParam{}
@@ -71,6 +89,39 @@ func TestConfig_Add_ValidationErrors(t *testing.T) {
 			},
 			wantErr: "name is required",
 		},
+		{
+			name: "name with parent directory",
+			param: ConfigParam{
+				Name:         "../x",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+			},
+			wantErr: "name is not valid",
+		},
+		{
+			name: "name with slash",
+			param: ConfigParam{
+				Name:         "a/b",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+			},
+			wantErr: "name is not valid",
+		},
+		{
+			name: "name with backslash",
+			param: ConfigParam{
+				Name:         "a\\b",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+			},
+			wantErr: "name is not valid",
+		},
 		{
 			name: "port too low",
 			param: ConfigParam{
@@ -159,6 +210,300 @@ func TestConfig_Add_ValidationErrors(t *testing.T) {
 			},
 			wantErr: "instance name is required",
 		},
+		{
+			name: "invalid engine",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				Engine:       "oracle",
+			},
+			wantErr: "engine is not valid",
+		},
+		{
+			name: "database without engine",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				Database:     "app",
+			},
+			wantErr: "engine is required when database or user is set",
+		},
+		{
+			name: "user without engine",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				User:         "app-user",
+			},
+			wantErr: "engine is required when database or user is set",
+		},
+		{
+			name: "invalid database",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				Engine:       "postgres",
+				Database:     "app db",
+			},
+			wantErr: "database name is not valid",
+		},
+		{
+			name: "invalid user",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				Engine:       "postgres",
+				User:         "app\nuser",
+			},
+			wantErr: "user is not valid",
+		},
+		{
+			name: "invalid password secret",
+			param: ConfigParam{
+				Name:           "test-config",
+				Port:           50000,
+				ProjectName:    "test-project",
+				Region:         "asia-northeast1",
+				InstanceName:   "test-instance",
+				PasswordSecret: "db-pass",
+			},
+			wantErr: "password secret is not valid",
+		},
+		{
+			name: "password key and password secret",
+			param: ConfigParam{
+				Name:           "test-config",
+				Port:           50000,
+				ProjectName:    "test-project",
+				Region:         "asia-northeast1",
+				InstanceName:   "test-instance",
+				PasswordKey:    "test-config",
+				PasswordSecret: "projects/test-project/secrets/db-pass/versions/latest",
+			},
+			wantErr: "password key and password secret cannot be used together",
+		},
+		{
+			name: "invalid ip type",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				IPType:       "internal",
+			},
+			wantErr: "ip type is not valid",
+		},
+		{
+			name: "invalid impersonate service account",
+			param: ConfigParam{
+				Name:                      "test-config",
+				Port:                      50000,
+				ProjectName:               "test-project",
+				Region:                    "asia-northeast1",
+				InstanceName:              "test-instance",
+				ImpersonateServiceAccount: "dev@example.com",
+			},
+			wantErr: "impersonate service account is not valid",
+		},
+		{
+			name: "empty entry in impersonation chain",
+			param: ConfigParam{
+				Name:                      "test-config",
+				Port:                      50000,
+				ProjectName:               "test-project",
+				Region:                    "asia-northeast1",
+				InstanceName:              "test-instance",
+				ImpersonateServiceAccount: "reader@test-project.iam.gserviceaccount.com,",
+			},
+			wantErr: "impersonate service account is not valid",
+		},
+		{
+			name: "invalid gcloud configuration",
+			param: ConfigParam{
+				Name:                "test-config",
+				Port:                50000,
+				ProjectName:         "test-project",
+				Region:              "asia-northeast1",
+				InstanceName:        "test-instance",
+				GcloudConfiguration: "Client A",
+			},
+			wantErr: "gcloud configuration is not valid",
+		},
+		{
+			name: "relative credentials file",
+			param: ConfigParam{
+				Name:            "test-config",
+				Port:            50000,
+				ProjectName:     "test-project",
+				Region:          "asia-northeast1",
+				InstanceName:    "test-instance",
+				CredentialsFile: "keys/client-a.json",
+			},
+			wantErr: "credentials file must be an absolute path",
+		},
+		{
+			name: "missing credentials file",
+			param: ConfigParam{
+				Name:            "test-config",
+				Port:            50000,
+				ProjectName:     "test-project",
+				Region:          "asia-northeast1",
+				InstanceName:    "test-instance",
+				CredentialsFile: "/nonexistent/client-a.json",
+			},
+			wantErr: "credentials file does not exist",
+		},
+		{
+			name: "health check port same as port",
+			param: ConfigParam{
+				Name:            "test-config",
+				Port:            50000,
+				ProjectName:     "test-project",
+				Region:          "asia-northeast1",
+				InstanceName:    "test-instance",
+				HealthCheckPort: 50000,
+			},
+			wantErr: "health check port is not valid",
+		},
+		{
+			name: "invalid idle timeout",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				IdleTimeout:  "30",
+			},
+			wantErr: "idle timeout is not valid",
+		},
+		{
+			name: "negative idle timeout",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				IdleTimeout:  "-5m",
+			},
+			wantErr: "idle timeout is not valid",
+		},
+		{
+			name: "too short idle timeout",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				IdleTimeout:  "3ns",
+			},
+			wantErr: "idle timeout must be at least 1s",
+		},
+		{
+			name: "invalid environment",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				Environment:  "production",
+			},
+			wantErr: "environment is not valid",
+		},
+		{
+			name: "zero max session duration",
+			param: ConfigParam{
+				Name:               "test-config",
+				Port:               50000,
+				ProjectName:        "test-project",
+				Region:             "asia-northeast1",
+				InstanceName:       "test-instance",
+				MaxSessionDuration: "0s",
+			},
+			wantErr: "max session duration is not valid",
+		},
+		{
+			name: "too short max session duration",
+			param: ConfigParam{
+				Name:               "test-config",
+				Port:               50000,
+				ProjectName:        "test-project",
+				Region:             "asia-northeast1",
+				InstanceName:       "test-instance",
+				MaxSessionDuration: "5ns",
+			},
+			wantErr: "max session duration must be at least 1s",
+		},
+		{
+			name: "health check port out of range",
+			param: ConfigParam{
+				Name:            "test-config",
+				Port:            50000,
+				ProjectName:     "test-project",
+				Region:          "asia-northeast1",
+				InstanceName:    "test-instance",
+				HealthCheckPort: 70000,
+			},
+			wantErr: "health check port is not valid",
+		},
+		{
+			name: "IAM authentication without engine",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				AutoIAMAuthn: true,
+			},
+			wantErr: "engine is required when IAM authentication is enabled",
+		},
+		{
+			name: "IAM authentication with sqlserver",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				Engine:       "sqlserver",
+				AutoIAMAuthn: true,
+			},
+			wantErr: "engine does not support IAM authentication",
+		},
+		{
+			name: "IAM authentication with password",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				Engine:       "postgres",
+				PasswordKey:  "test-config",
+				AutoIAMAuthn: true,
+			},
+			wantErr: "password cannot be used with IAM authentication",
+		},
 	}
 
 	for _, tt := range tests {
@@ -175,6 +520,30 @@ func TestConfig_Add_ValidationErrors(t *testing.T) {
 	}
 }
 
+func TestConfigParam_HasPassword(t *testing.T) {
+	assert.False(t, ConfigParam{}.HasPassword())
+	assert.True(t, ConfigParam{PasswordKey: "test-config"}.HasPassword())
+	assert.True(t, ConfigParam{PasswordSecret: "projects/p/secrets/db-pass"}.HasPassword())
+}
+
+func TestConfigParam_IdleTimeoutDuration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), ConfigParam{}.IdleTimeoutDuration())
+	assert.Equal(t, 30*time.Minute, ConfigParam{IdleTimeout: "30m"}.IdleTimeoutDuration())
+	assert.Equal(t, time.Duration(0), ConfigParam{IdleTimeout: "0"}.IdleTimeoutDuration())
+	assert.Equal(t, time.Duration(0), ConfigParam{IdleTimeout: "soon"}.IdleTimeoutDuration())
+}
+
+func TestConfigParam_IsProd(t *testing.T) {
+	assert.False(t, ConfigParam{}.IsProd())
+	assert.False(t, ConfigParam{Environment: EnvironmentStaging}.IsProd())
+	assert.True(t, ConfigParam{Environment: EnvironmentProd}.IsProd())
+}
+
+func TestConfigParam_MaxSession(t *testing.T) {
+	assert.Equal(t, time.Duration(0), ConfigParam{}.MaxSession())
+	assert.Equal(t, time.Hour, ConfigParam{MaxSessionDuration: "1h"}.MaxSession())
+}
+
 func TestConfig_Add_ValidBoundaryValues(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -220,6 +589,64 @@ func TestConfig_Add_ValidBoundaryValues(t *testing.T) {
 				InstanceName: "test-instance",
 			},
 		},
+		{
+			name: "engine with database and user",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				Engine:       "mysql",
+				Database:     "app",
+				User:         "app-user",
+			},
+		},
+		{
+			name: "password secret",
+			param: ConfigParam{
+				Name:           "test-config",
+				Port:           50000,
+				ProjectName:    "test-project",
+				Region:         "asia-northeast1",
+				InstanceName:   "test-instance",
+				PasswordSecret: "projects/test-project/secrets/db-pass/versions/latest",
+			},
+		},
+		{
+			name: "IAM authentication",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				Engine:       "postgres",
+				AutoIAMAuthn: true,
+			},
+		},
+		{
+			name: "private ip",
+			param: ConfigParam{
+				Name:         "test-config",
+				Port:         50000,
+				ProjectName:  "test-project",
+				Region:       "asia-northeast1",
+				InstanceName: "test-instance",
+				IPType:       "private",
+			},
+		},
+		{
+			name: "impersonation chain",
+			param: ConfigParam{
+				Name:                      "test-config",
+				Port:                      50000,
+				ProjectName:               "test-project",
+				Region:                    "asia-northeast1",
+				InstanceName:              "test-instance",
+				ImpersonateServiceAccount: "delegate@test-project.iam.gserviceaccount.com,reader@test-project.iam.gserviceaccount.com",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -236,6 +663,31 @@ func TestConfig_Add_ValidBoundaryValues(t *testing.T) {
 	}
 }
 
+func TestConfig_Add_GcloudContext(t *testing.T) {
+	credentialsFile := filepath.Join(t.TempDir(), "client-a.json")
+	require.NoError(t, os.WriteFile(credentialsFile, []byte("{}"), 0600))
+
+	mockRepo := &mockRepository{}
+	config := NewConfig(mockRepo)
+
+	param := ConfigParam{
+		Name:                "test-config",
+		Port:                50000,
+		ProjectName:         "test-project",
+		Region:              "asia-northeast1",
+		InstanceName:        "test-instance",
+		GcloudConfiguration: "client-a",
+		CredentialsFile:     credentialsFile,
+	}
+
+	err := config.Add(param)
+
+	assert.NoError(t, err)
+	assert.Equal(t, param, mockRepo.saveParam)
+	assert.Equal(t, "client-a", param.GcloudContext().Configuration)
+	assert.Equal(t, credentialsFile, param.GcloudContext().CredentialsFile)
+}
+
 func TestConfig_Add_RepositoryError(t *testing.T) {
 	mockRepo := &mockRepository{}
 	config := NewConfig(mockRepo)
@@ -280,6 +732,71 @@ func TestConfig_Add_MultipleValidationErrors(t *testing.T) {
 	assert.False(t, mockRepo.saveCalled)
 }
 
+func TestConfig_Update(t *testing.T) {
+	mockRepo := &mockRepository{}
+	config := NewConfig(mockRepo)
+
+	param := ConfigParam{
+		Name:         "test-config",
+		Port:         50000,
+		ProjectName:  "test-project",
+		Region:       "asia-northeast1",
+		InstanceName: "test-instance",
+	}
+
+	err := config.Update(param)
+
+	assert.NoError(t, err)
+	assert.True(t, mockRepo.updateCalled)
+	assert.Equal(t, param, mockRepo.updateParam)
+}
+
+func TestConfig_Update_ValidationError(t *testing.T) {
+	mockRepo := &mockRepository{}
+	config := NewConfig(mockRepo)
+
+	err := config.Update(ConfigParam{Name: "test-config"})
+
+	assert.EqualError(t, err, "port is out of range")
+	assert.False(t, mockRepo.updateCalled)
+}
+
+func TestConfig_Get(t *testing.T) {
+	mockRepo := &mockRepository{
+		findAllParam: []ConfigParam{
+			{Name: "config1", Port: 50001},
+			{Name: "config2", Port: 50002},
+		},
+	}
+	config := NewConfig(mockRepo)
+
+	param, err := config.Get("config2")
+	require.NoError(t, err)
+	assert.Equal(t, 50002, param.Port)
+
+	_, err = config.Get("unknown")
+	assert.EqualError(t, err, `config "unknown" not found`)
+}
+
+func TestConfig_Get_RepositoryError(t *testing.T) {
+	expectedError := errors.New("repository load failed")
+	mockRepo := &mockRepository{findAllError: expectedError}
+	config := NewConfig(mockRepo)
+
+	_, err := config.Get("config1")
+	assert.Equal(t, expectedError, err)
+}
+
+func TestConfig_List(t *testing.T) {
+	params := []ConfigParam{{Name: "config1"}, {Name: "config2"}}
+	mockRepo := &mockRepository{findAllParam: params}
+	config := NewConfig(mockRepo)
+
+	list, err := config.List()
+	require.NoError(t, err)
+	assert.Equal(t, params, list)
+}
+
 // benchmark test
 func BenchmarkConfig_Add(b *testing.B) {
 	mockRepo := &mockRepository{}
@@ -2,4 +2,6 @@ package config
 
 type Repository interface {
 	Save(config ConfigParam) error
+	Update(config ConfigParam) error
+	FindAll() ([]ConfigParam, error)
 }
@@ -0,0 +1,107 @@
+package connection
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+)
+
+// LocalHost is the address the Cloud SQL Auth Proxy listens on.
+// An IP address is used so that MySQL clients do not fall back to a Unix socket.
+const LocalHost = "127.0.0.1"
+
+type Info struct {
+	Engine   cloud.Engine
+	Host     string
+	Port     int
+	Database string
+	User     string
+	Password string
+}
+
+func NewInfo(param config.ConfigParam) Info {
+	return Info{
+		Engine:   param.Engine,
+		Host:     LocalHost,
+		Port:     param.Port,
+		Database: param.Database,
+		User:     param.User,
+	}
+}
+
+func (i Info) Address() string {
+	return net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+}
+
+func (i Info) URL() string {
+	return i.url(string(i.Engine))
+}
+
+func (i Info) url(scheme string) string {
+	u := url.URL{
+		Scheme: scheme,
+		Host:   i.Address(),
+	}
+	if len(i.User) > 0 {
+		u.User = url.User(i.User)
+		if len(i.Password) > 0 {
+			u.User = url.UserPassword(i.User, i.Password)
+		}
+	}
+	if len(i.Database) > 0 {
+		// Only the sqlserver scheme takes the database as a parameter, SQLAlchemy's mssql+pymssql in the path
+		if scheme == string(cloud.EngineSQLServer) {
+			u.RawQuery = url.Values{"database": {i.Database}}.Encode()
+		} else {
+			u.Path = "/" + i.Database
+		}
+	}
+	return u.String()
+}
+
+type Var struct {
+	Key   string
+	Value string
+}
+
+// Vars returns the environment variables understood by the engine's clients.
+func (i Info) Vars() []Var {
+	vars := []Var{{Key: "DATABASE_URL", Value: i.URL()}}
+	switch i.Engine {
+	case cloud.EnginePostgres:
+		vars = append(vars,
+			Var{Key: "PGHOST", Value: i.Host},
+			Var{Key: "PGPORT", Value: strconv.Itoa(i.Port)},
+		)
+		if len(i.Database) > 0 {
+			vars = append(vars, Var{Key: "PGDATABASE", Value: i.Database})
+		}
+		if len(i.User) > 0 {
+			vars = append(vars, Var{Key: "PGUSER", Value: i.User})
+		}
+		if len(i.Password) > 0 {
+			vars = append(vars, Var{Key: "PGPASSWORD", Value: i.Password})
+		}
+	case cloud.EngineMySQL:
+		vars = append(vars,
+			Var{Key: "MYSQL_HOST", Value: i.Host},
+			Var{Key: "MYSQL_TCP_PORT", Value: strconv.Itoa(i.Port)},
+		)
+		if len(i.Password) > 0 {
+			vars = append(vars, Var{Key: "MYSQL_PWD", Value: i.Password})
+		}
+	}
+	return vars
+}
+
+// Env returns Vars in KEY=VALUE form.
+func (i Info) Env() []string {
+	var env []string
+	for _, v := range i.Vars() {
+		env = append(env, v.Key+"="+v.Value)
+	}
+	return env
+}
@@ -0,0 +1,170 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+)
+
+type Format string
+
+const (
+	FormatURL        Format = "url"
+	FormatLibpq      Format = "libpq"
+	FormatJDBC       Format = "jdbc"
+	FormatGoMySQL    Format = "go-mysql"
+	FormatSQLAlchemy Format = "sqlalchemy"
+	FormatPrisma     Format = "prisma"
+)
+
+func GetFormatList() []Format {
+	return []Format{
+		FormatURL,
+		FormatLibpq,
+		FormatJDBC,
+		FormatGoMySQL,
+		FormatSQLAlchemy,
+		FormatPrisma,
+	}
+}
+
+// DSN renders the connection as a connection string in the given format.
+func (i Info) DSN(format Format) (string, error) {
+	switch format {
+	case FormatURL:
+		return i.URL(), nil
+	case FormatLibpq:
+		if i.Engine == cloud.EnginePostgres {
+			return i.libpq(), nil
+		}
+	case FormatJDBC:
+		return i.jdbc(), nil
+	case FormatGoMySQL:
+		if i.Engine == cloud.EngineMySQL {
+			return i.goMySQL()
+		}
+	case FormatSQLAlchemy:
+		return i.url(sqlAlchemyDialects[i.Engine]), nil
+	case FormatPrisma:
+		if i.Engine == cloud.EngineSQLServer {
+			return "sqlserver://" + i.Address() + i.properties(";", "database"), nil
+		}
+		return i.url(prismaSchemes[i.Engine]), nil
+	default:
+		return "", fmt.Errorf("format %q is not supported", format)
+	}
+	return "", fmt.Errorf("format %q does not support engine %q", format, i.Engine)
+}
+
+var sqlAlchemyDialects = map[cloud.Engine]string{
+	cloud.EnginePostgres:  "postgresql+psycopg2",
+	cloud.EngineMySQL:     "mysql+pymysql",
+	cloud.EngineSQLServer: "mssql+pymssql",
+}
+
+var prismaSchemes = map[cloud.Engine]string{
+	cloud.EnginePostgres: "postgresql",
+	cloud.EngineMySQL:    "mysql",
+}
+
+func (i Info) libpq() string {
+	params := []string{
+		"host=" + libpqQuote(i.Host),
+		"port=" + strconv.Itoa(i.Port),
+	}
+	if len(i.Database) > 0 {
+		params = append(params, "dbname="+libpqQuote(i.Database))
+	}
+	if len(i.User) > 0 {
+		params = append(params, "user="+libpqQuote(i.User))
+	}
+	if len(i.Password) > 0 {
+		params = append(params, "password="+libpqQuote(i.Password))
+	}
+	return strings.Join(params, " ")
+}
+
+// libpqQuote quotes a keyword/value connection string value when it is empty or contains spaces or quotes.
+func libpqQuote(value string) string {
+	if len(value) > 0 && !strings.ContainsAny(value, " '\\") {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func (i Info) jdbc() string {
+	switch i.Engine {
+	case cloud.EngineSQLServer:
+		return "jdbc:sqlserver://" + i.Address() + i.properties(";", "databaseName")
+	case cloud.EnginePostgres:
+		return "jdbc:postgresql://" + i.Address() + "/" + url.PathEscape(i.Database) + i.query()
+	default:
+		return "jdbc:" + string(i.Engine) + "://" + i.Address() + "/" + url.PathEscape(i.Database) + i.query()
+	}
+}
+
+// properties renders the database and credentials as sep-prefixed key=value pairs.
+func (i Info) properties(sep string, databaseKey string) string {
+	var b strings.Builder
+	if len(i.Database) > 0 {
+		b.WriteString(sep + databaseKey + "=" + propertyQuote(i.Database))
+	}
+	if len(i.User) > 0 {
+		b.WriteString(sep + "user=" + propertyQuote(i.User))
+	}
+	if len(i.Password) > 0 {
+		b.WriteString(sep + "password={" + strings.ReplaceAll(i.Password, "}", "}}") + "}")
+	}
+	return b.String()
+}
+
+// propertyQuote braces a property value that contains separators or braces, so that it
+// cannot add properties of its own.
+func propertyQuote(value string) string {
+	if !strings.ContainsAny(value, ";={}") && strings.TrimSpace(value) == value {
+		return value
+	}
+	return "{" + strings.ReplaceAll(value, "}", "}}") + "}"
+}
+
+func (i Info) query() string {
+	values := url.Values{}
+	if len(i.User) > 0 {
+		values.Set("user", i.User)
+	}
+	if len(i.Password) > 0 {
+		values.Set("password", i.Password)
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+// goMySQL renders the DSN of go-sql-driver/mysql, which has no escaping. The driver splits
+// the credentials at the last '@' and the first ':' and the database at the last '/', so the
+// password can hold any character but the user cannot contain ':' and the database '/' or '?'.
+func (i Info) goMySQL() (string, error) {
+	if strings.Contains(i.User, ":") {
+		return "", errors.New("format \"go-mysql\" does not support users containing ':'")
+	}
+	if strings.ContainsAny(i.Database, "/?") {
+		return "", errors.New("format \"go-mysql\" does not support databases containing '/' or '?'")
+	}
+	var b strings.Builder
+	if len(i.User) > 0 {
+		b.WriteString(i.User)
+		if len(i.Password) > 0 {
+			b.WriteString(":" + i.Password)
+		}
+		b.WriteString("@")
+	}
+	b.WriteString("tcp(" + i.Address() + ")/" + i.Database)
+	return b.String(), nil
+}
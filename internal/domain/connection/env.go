@@ -0,0 +1,106 @@
+package connection
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type EnvFormat string
+
+const (
+	EnvFormatDotenv EnvFormat = "dotenv"
+	EnvFormatShell  EnvFormat = "shell"
+	EnvFormatFish   EnvFormat = "fish"
+	EnvFormatDirenv EnvFormat = "direnv"
+	EnvFormatJSON   EnvFormat = "json"
+)
+
+func GetEnvFormatList() []EnvFormat {
+	return []EnvFormat{
+		EnvFormatDotenv,
+		EnvFormatShell,
+		EnvFormatFish,
+		EnvFormatDirenv,
+		EnvFormatJSON,
+	}
+}
+
+var (
+	invalidKeyChars = regexp.MustCompile(`[^A-Z0-9_]`)
+	plainValue      = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+)
+
+// WithPrefix returns vars with their keys prefixed, so that several connections can be exported side by side.
+// The prefix is upper-cased and joined to the key with an underscore.
+func WithPrefix(vars []Var, prefix string) []Var {
+	if len(prefix) == 0 {
+		return vars
+	}
+	prefix = invalidKeyChars.ReplaceAllString(strings.ToUpper(prefix), "_")
+	if !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	prefixed := make([]Var, 0, len(vars))
+	for _, v := range vars {
+		prefixed = append(prefixed, Var{Key: prefix + v.Key, Value: v.Value})
+	}
+	return prefixed
+}
+
+// RenderEnv renders vars in the given format.
+func RenderEnv(vars []Var, format EnvFormat) (string, error) {
+	var b strings.Builder
+	switch format {
+	case EnvFormatDotenv:
+		for _, v := range vars {
+			fmt.Fprintf(&b, "%s=%s\n", v.Key, dotenvQuote(v.Value))
+		}
+	case EnvFormatShell, EnvFormatDirenv:
+		for _, v := range vars {
+			fmt.Fprintf(&b, "export %s=%s\n", v.Key, shellQuote(v.Value))
+		}
+	case EnvFormatFish:
+		for _, v := range vars {
+			fmt.Fprintf(&b, "set -gx %s %s;\n", v.Key, fishQuote(v.Value))
+		}
+	case EnvFormatJSON:
+		m := make(map[string]string, len(vars))
+		for _, v := range vars {
+			m[v.Key] = v.Value
+		}
+		data, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		b.Write(data)
+		b.WriteString("\n")
+	default:
+		return "", fmt.Errorf("format %q is not supported", format)
+	}
+	return b.String(), nil
+}
+
+func dotenvQuote(value string) string {
+	if plainValue.MatchString(value) {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`)
+	return `"` + r.Replace(value) + `"`
+}
+
+func shellQuote(value string) string {
+	if len(value) > 0 && plainValue.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func fishQuote(value string) string {
+	if len(value) > 0 && plainValue.MatchString(value) {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(value) + "'"
+}
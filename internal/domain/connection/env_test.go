@@ -0,0 +1,79 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEnvFormatList(t *testing.T) {
+	assert.Equal(t, []EnvFormat{"dotenv", "shell", "fish", "direnv", "json"}, GetEnvFormatList())
+}
+
+func TestWithPrefix(t *testing.T) {
+	vars := []Var{
+		{Key: "DATABASE_URL", Value: "postgres://127.0.0.1:50000"},
+		{Key: "PGPORT", Value: "50000"},
+	}
+
+	assert.Equal(t, vars, WithPrefix(vars, ""))
+	assert.Equal(t, []Var{
+		{Key: "BILLING_DB_DATABASE_URL", Value: "postgres://127.0.0.1:50000"},
+		{Key: "BILLING_DB_PGPORT", Value: "50000"},
+	}, WithPrefix(vars, "billing-db"))
+	assert.Equal(t, "BILLING_PGPORT", WithPrefix(vars, "BILLING_")[1].Key)
+}
+
+func TestRenderEnv(t *testing.T) {
+	vars := []Var{
+		{Key: "DATABASE_URL", Value: "postgres://app-user@127.0.0.1:50000/app"},
+		{Key: "PGPASSWORD", Value: `it's "$ecret"`},
+	}
+
+	tests := []struct {
+		format EnvFormat
+		want   string
+	}{
+		{
+			format: EnvFormatDotenv,
+			want: "DATABASE_URL=postgres://app-user@127.0.0.1:50000/app\n" +
+				`PGPASSWORD="it's \"\$ecret\""` + "\n",
+		},
+		{
+			format: EnvFormatShell,
+			want: "export DATABASE_URL=postgres://app-user@127.0.0.1:50000/app\n" +
+				`export PGPASSWORD='it'\''s "$ecret"'` + "\n",
+		},
+		{
+			format: EnvFormatDirenv,
+			want: "export DATABASE_URL=postgres://app-user@127.0.0.1:50000/app\n" +
+				`export PGPASSWORD='it'\''s "$ecret"'` + "\n",
+		},
+		{
+			format: EnvFormatFish,
+			want: "set -gx DATABASE_URL postgres://app-user@127.0.0.1:50000/app;\n" +
+				`set -gx PGPASSWORD 'it\'s "$ecret"';` + "\n",
+		},
+		{
+			format: EnvFormatJSON,
+			want: "{\n" +
+				`  "DATABASE_URL": "postgres://app-user@127.0.0.1:50000/app",` + "\n" +
+				`  "PGPASSWORD": "it's \"$ecret\""` + "\n" +
+				"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			out, err := RenderEnv(vars, tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out)
+		})
+	}
+}
+
+func TestRenderEnv_Unsupported(t *testing.T) {
+	_, err := RenderEnv(nil, "yaml")
+	assert.EqualError(t, err, `format "yaml" is not supported`)
+}
@@ -0,0 +1,144 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+)
+
+// Client talks to a daemon over its Unix socket.
+type Client struct {
+	socketPath string
+}
+
+func NewClient(socketPath string) *Client {
+	return &Client{socketPath: socketPath}
+}
+
+// RemoteError is an error returned by the daemon that carries a machine-readable code.
+type RemoteError struct {
+	Message string
+	ErrCode string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+func (e *RemoteError) Code() string {
+	return e.ErrCode
+}
+
+func (c *Client) dial(req Request) (net.Conn, *bufio.Reader, error) {
+	conn, err := net.Dial("unix", c.socketPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, bufio.NewReader(conn), nil
+}
+
+func (c *Client) call(req Request) (*Response, error) {
+	conn, reader, err := c.dial(req)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var resp Response
+	err = json.NewDecoder(reader).Decode(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code != "" {
+		return nil, &RemoteError{Message: resp.Error, ErrCode: resp.Code}
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// Start starts the proxy for the config, restarting it up to maxRetries consecutive times.
+// A positive session time-boxes the proxy.
+func (c *Client) Start(name string, session time.Duration, maxRetries int) (*proxy.State, error) {
+	req := Request{Method: MethodStart, Name: name, MaxRetries: &maxRetries}
+	if session > 0 {
+		req.Session = session.String()
+	}
+	resp, err := c.call(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.State, nil
+}
+
+func (c *Client) Stop(name string) error {
+	_, err := c.call(Request{Method: MethodStop, Name: name})
+	return err
+}
+
+func (c *Client) Status() ([]proxy.State, error) {
+	resp, err := c.call(Request{Method: MethodStatus})
+	if err != nil {
+		return nil, err
+	}
+	if resp.States == nil {
+		return []proxy.State{}, nil
+	}
+	return resp.States, nil
+}
+
+// Logs returns the last lines of the proxy's output, or all of them when lines is not positive.
+func (c *Client) Logs(name string, lines int) ([]string, error) {
+	resp, err := c.call(Request{Method: MethodLogs, Name: name, Lines: lines})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Logs, nil
+}
+
+// Subscribe streams the daemon's events until ctx is done or the daemon goes away,
+// then closes the returned channel.
+func (c *Client) Subscribe(ctx context.Context) (<-chan Event, error) {
+	conn, reader, err := c.dial(Request{Method: MethodSubscribe})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		decoder := json.NewDecoder(reader)
+		for {
+			var resp Response
+			if decoder.Decode(&resp) != nil || resp.Event == nil {
+				return
+			}
+			select {
+			case events <- *resp.Event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,459 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/logs"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+)
+
+// Methods of the control API. Each connection carries one newline terminated JSON Request,
+// answered by one Response, or by a stream of Responses holding events for MethodSubscribe.
+const (
+	MethodStart     = "start"
+	MethodStop      = "stop"
+	MethodStatus    = "status"
+	MethodLogs      = "logs"
+	MethodSubscribe = "subscribe"
+)
+
+type EventType string
+
+const (
+	EventState   EventType = "state"
+	EventStopped EventType = "stopped"
+	EventLog     EventType = "log"
+)
+
+// logLines is the number of output lines kept per proxy.
+const logLines = 1000
+
+type Request struct {
+	Method string
+	Name   string `json:",omitempty"`
+	Lines  int    `json:",omitempty"`
+	// Session is the duration of a time-boxed session for MethodStart, such as "30m".
+	Session string `json:",omitempty"`
+	// MaxRetries is the number of consecutive restarts of the proxy for MethodStart before giving
+	// up, 0 not restarting it. The daemon's restart policy applies when it is not set.
+	MaxRetries *int `json:",omitempty"`
+}
+
+type Response struct {
+	Error  string        `json:",omitempty"`
+	Code   string        `json:",omitempty"`
+	State  *proxy.State  `json:",omitempty"`
+	States []proxy.State `json:",omitempty"`
+	Logs   []string      `json:",omitempty"`
+	Event  *Event        `json:",omitempty"`
+}
+
+type Event struct {
+	Type  EventType
+	Name  string
+	State *proxy.State `json:",omitempty"`
+	Line  string       `json:",omitempty"`
+}
+
+type supervised struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Server supervises proxies in the daemon process and serves the control API.
+type Server struct {
+	r            proxy.Repository
+	proxy        *proxy.Proxy
+	get          func(name string) (config.ConfigParam, error)
+	policy       proxy.RestartPolicy
+	startTimeout time.Duration
+	logFiles     *logs.Store
+
+	mu      sync.Mutex // guards the fields below
+	ctx     context.Context
+	running map[string]*supervised
+	logs    map[string]*logBuffer
+
+	subMu       sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewServer returns a server running proxies built by newProxy with get's configs.
+// The repository passed to newProxy reports state changes to subscribers.
+func NewServer(r proxy.Repository, newProxy func(r proxy.Repository) *proxy.Proxy, get func(name string) (config.ConfigParam, error)) *Server {
+	s := &Server{
+		get:          get,
+		policy:       proxy.DefaultRestartPolicy(),
+		startTimeout: 30 * time.Second,
+		ctx:          context.Background(),
+		running:      map[string]*supervised{},
+		logs:         map[string]*logBuffer{},
+		subscribers:  map[chan Event]struct{}{},
+	}
+	s.r = &publishingRepository{Repository: r, publish: s.publish}
+	s.proxy = newProxy(s.r)
+	return s
+}
+
+// SetLogStore makes the server write the output of the proxies it starts to their log files too.
+func (s *Server) SetLogStore(store *logs.Store) {
+	s.logFiles = store
+}
+
+// Serve accepts connections on l until ctx is done, then stops the proxies it started.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer s.stopAll()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	var req Request
+	err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req)
+	if err != nil {
+		writeResponse(conn, errorResponse(err))
+		return
+	}
+
+	var resp Response
+	switch req.Method {
+	case MethodStart:
+		var session time.Duration
+		if req.Session != "" {
+			session, err = time.ParseDuration(req.Session)
+		}
+		maxRetries := s.policy.MaxRetries
+		if req.MaxRetries != nil {
+			maxRetries = *req.MaxRetries
+		}
+		if err == nil {
+			resp.State, err = s.Start(req.Name, session, maxRetries)
+		}
+	case MethodStop:
+		err = s.Stop(req.Name)
+	case MethodStatus:
+		resp.States, err = s.Status()
+	case MethodLogs:
+		resp.Logs, err = s.Logs(req.Name, req.Lines)
+	case MethodSubscribe:
+		s.stream(ctx, conn)
+		return
+	default:
+		err = fmt.Errorf("method %q is not supported", req.Method)
+	}
+	if err != nil {
+		resp = errorResponse(err)
+	}
+	writeResponse(conn, resp)
+}
+
+// stream writes events to conn until the client goes away or ctx is done.
+func (s *Server) stream(ctx context.Context, conn net.Conn) {
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+	closed := make(chan struct{})
+	go func() {
+		// Subscribers do not send anything after the request, so a read returns when they leave
+		io.Copy(io.Discard, conn)
+		close(closed)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-closed:
+			return
+		case event := <-events:
+			if writeResponse(conn, Response{Event: &event}) != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeResponse(w io.Writer, resp Response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func errorResponse(err error) Response {
+	resp := Response{Error: err.Error()}
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		resp.Code = coded.Code()
+	}
+	return resp
+}
+
+// Start starts and supervises the proxy for the config and waits until it listens. The proxy is
+// restarted up to maxRetries consecutive times. A positive session time-boxes the proxy.
+func (s *Server) Start(name string, session time.Duration, maxRetries int) (*proxy.State, error) {
+	param, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	if session > 0 {
+		param, err = proxy.WithSession(param, session)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	if _, ok := s.running[name]; ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("proxy for %q is already running", name)
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	sup := &supervised{cancel: cancel, done: make(chan struct{})}
+	s.running[name] = sup
+	output := s.logBuffer(name)
+	s.mu.Unlock()
+
+	err = s.proxy.CheckStart(param)
+	if err != nil {
+		s.mu.Lock()
+		delete(s.running, name)
+		s.mu.Unlock()
+		cancel()
+		return nil, err
+	}
+
+	var file io.WriteCloser
+	if s.logFiles != nil {
+		file, err = s.logFiles.Writer(name)
+		if err != nil {
+			fmt.Fprintf(output, "tsunagi: %v\n", err)
+		}
+	}
+
+	go func() {
+		var w io.Writer = output
+		if file != nil {
+			defer file.Close()
+			w = io.MultiWriter(output, file)
+		}
+		policy := s.policy
+		policy.MaxRetries = maxRetries
+		sup.err = s.proxy.Supervise(ctx, param, policy, w)
+		if sup.err != nil {
+			fmt.Fprintf(w, "tsunagi: %v\n", sup.err)
+		}
+		s.mu.Lock()
+		delete(s.running, name)
+		s.mu.Unlock()
+		close(sup.done)
+	}()
+
+	deadline := time.After(s.startTimeout)
+	for {
+		state, err := s.r.Find(name)
+		if err != nil {
+			return nil, err
+		}
+		if state != nil && state.PID != 0 {
+			return state, nil
+		}
+		select {
+		case <-sup.done:
+			if sup.err == nil {
+				return nil, errors.New("proxy stopped while starting")
+			}
+			return nil, sup.err
+		case <-deadline:
+			cancel()
+			<-sup.done
+			return nil, errors.New("timed out waiting for proxy to listen")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Stop stops the proxy for the config. Proxies started without the daemon are stopped too.
+func (s *Server) Stop(name string) error {
+	s.mu.Lock()
+	sup, ok := s.running[name]
+	s.mu.Unlock()
+	if !ok {
+		return s.proxy.Stop(name)
+	}
+	sup.cancel()
+	<-sup.done
+	return nil
+}
+
+func (s *Server) stopAll() {
+	s.mu.Lock()
+	running := make([]*supervised, 0, len(s.running))
+	for _, sup := range s.running {
+		running = append(running, sup)
+	}
+	s.mu.Unlock()
+	for _, sup := range running {
+		sup.cancel()
+		<-sup.done
+	}
+}
+
+// Status returns the states of all proxies, including the ones started without the daemon.
+func (s *Server) Status() ([]proxy.State, error) {
+	return s.proxy.List()
+}
+
+// Logs returns the last lines of the proxy's output, or all of them when lines is not positive.
+func (s *Server) Logs(name string, lines int) ([]string, error) {
+	s.mu.Lock()
+	buf, ok := s.logs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no logs for %q", name)
+	}
+	return buf.Tail(lines), nil
+}
+
+// Subscribe returns a channel receiving state changes and log lines of all proxies.
+// Events are dropped for subscribers that do not keep up.
+func (s *Server) Subscribe() (<-chan Event, func()) {
+	events := make(chan Event, 64)
+	s.subMu.Lock()
+	s.subscribers[events] = struct{}{}
+	s.subMu.Unlock()
+	return events, func() {
+		s.subMu.Lock()
+		delete(s.subscribers, events)
+		s.subMu.Unlock()
+	}
+}
+
+func (s *Server) publish(event Event) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for events := range s.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// logBuffer returns the output buffer of the proxy. s.mu must be held.
+func (s *Server) logBuffer(name string) *logBuffer {
+	buf, ok := s.logs[name]
+	if !ok {
+		buf = &logBuffer{
+			onLine: func(line string) {
+				s.publish(Event{Type: EventLog, Name: name, Line: line})
+			},
+		}
+		s.logs[name] = buf
+	}
+	return buf
+}
+
+// publishingRepository reports saved and deleted states to the daemon's subscribers.
+type publishingRepository struct {
+	proxy.Repository
+	publish func(Event)
+}
+
+func (r *publishingRepository) Save(state proxy.State) error {
+	err := r.Repository.Save(state)
+	if err == nil {
+		r.publish(Event{Type: EventState, Name: state.Name, State: &state})
+	}
+	return err
+}
+
+func (r *publishingRepository) Delete(name string) error {
+	err := r.Repository.Delete(name)
+	if err == nil {
+		r.publish(Event{Type: EventStopped, Name: name})
+	}
+	return err
+}
+
+// logBuffer keeps the last logLines lines written to it.
+type logBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	partial string
+	onLine  func(line string)
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	data := b.partial + string(p)
+	parts := strings.Split(data, "\n")
+	b.partial = parts[len(parts)-1]
+	parts = parts[:len(parts)-1]
+	b.lines = append(b.lines, parts...)
+	if len(b.lines) > logLines {
+		b.lines = b.lines[len(b.lines)-logLines:]
+	}
+	b.mu.Unlock()
+	for _, line := range parts {
+		b.onLine(line)
+	}
+	return len(p), nil
+}
+
+func (b *logBuffer) Tail(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n <= 0 || n > len(b.lines) {
+		n = len(b.lines)
+	}
+	return append([]string{}, b.lines[len(b.lines)-n:]...)
+}
+
+// SocketAvailable reports whether a daemon is listening on the socket.
+func SocketAvailable(socketPath string) bool {
+	if _, err := os.Stat(socketPath); err != nil {
+		return false
+	}
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
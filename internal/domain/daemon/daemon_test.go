@@ -0,0 +1,340 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/logs"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary act as a fake cloud-sql-proxy that prints a line
+// and listens on the port given by --port until it is terminated.
+func TestMain(m *testing.M) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		os.Exit(m.Run())
+	}
+	var port string
+	for i, arg := range os.Args {
+		if arg == "--port" && i+1 < len(os.Args) {
+			port = os.Args[i+1]
+		}
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		os.Exit(2)
+	}
+	defer l.Close()
+	fmt.Println("Listening on 127.0.0.1:" + port)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	<-sig
+	os.Exit(0)
+}
+
+// mockRepository is an in-memory implementation of the proxy.Repository interface for testing
+type mockRepository struct {
+	mu       sync.Mutex
+	states   map[string]proxy.State
+	expiries map[string]time.Time
+}
+
+func (m *mockRepository) Save(state proxy.State) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[state.Name] = state
+	return nil
+}
+
+func (m *mockRepository) Find(name string) (*proxy.State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.states[name]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (m *mockRepository) FindAll() ([]proxy.State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	states := []proxy.State{}
+	for _, state := range m.states {
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (m *mockRepository) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, name)
+	delete(m.expiries, name)
+	return nil
+}
+
+func (m *mockRepository) SaveExpiry(name string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.expiries == nil {
+		m.expiries = map[string]time.Time{}
+	}
+	m.expiries[name] = expiresAt
+	return nil
+}
+
+func (m *mockRepository) FindExpiry(name string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.expiries[name], nil
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+type testDaemon struct {
+	client   *Client
+	repo     *mockRepository
+	param    config.ConfigParam
+	logs     *logs.Store
+	shutdown func()
+}
+
+// startServer serves a daemon for the "billing" config on a socket in a temporary directory.
+// The daemon is shut down at the end of the test unless shutdown was called before.
+func startServer(t *testing.T) *testDaemon {
+	t.Helper()
+	// gcloud passes the authentication pre-flight
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "gcloud"), []byte("#!/bin/sh\nexit 0\n"), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+
+	param := config.ConfigParam{
+		Name:         "billing",
+		Port:         freePort(t),
+		ProjectName:  "test-project",
+		Region:       "asia-northeast1",
+		InstanceName: "test-instance",
+	}
+	get := func(name string) (config.ConfigParam, error) {
+		if name != param.Name {
+			return config.ConfigParam{}, fmt.Errorf("config %q not found", name)
+		}
+		return param, nil
+	}
+	repo := &mockRepository{states: map[string]proxy.State{}}
+	server := NewServer(repo, func(r proxy.Repository) *proxy.Proxy {
+		p := proxy.NewProxy(r)
+		p.UseBinary(os.Args[0])
+		return p
+	}, get)
+	logStore := logs.NewStore(t.TempDir(), logs.DefaultRotation())
+	server.SetLogStore(logStore)
+
+	socketPath := filepath.Join(t.TempDir(), "tsunagi.sock")
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx, l)
+	}()
+	var once sync.Once
+	shutdown := func() {
+		once.Do(func() {
+			cancel()
+			assert.NoError(t, <-done)
+		})
+	}
+	t.Cleanup(shutdown)
+	return &testDaemon{client: NewClient(socketPath), repo: repo, param: param, logs: logStore, shutdown: shutdown}
+}
+
+func TestServer_StartStopStatus(t *testing.T) {
+	d := startServer(t)
+	client, repo, param := d.client, d.repo, d.param
+
+	state, err := client.Start("billing", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, param.Port, state.Port)
+	assert.Equal(t, os.Getpid(), state.Supervisor)
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(param.Port)))
+	require.NoError(t, err)
+	conn.Close()
+
+	_, err = client.Start("billing", 0, 0)
+	assert.EqualError(t, err, `proxy for "billing" is already running`)
+
+	states, err := client.Status()
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, proxy.PhaseRunning, states[0].Status())
+
+	require.NoError(t, client.Stop("billing"))
+	assert.Empty(t, repo.states)
+	states, err = client.Status()
+	require.NoError(t, err)
+	assert.Empty(t, states)
+
+	err = client.Stop("billing")
+	assert.EqualError(t, err, `proxy for "billing" is not running`)
+}
+
+func TestServer_StartSession(t *testing.T) {
+	d := startServer(t)
+
+	state, err := d.client.Start("billing", time.Hour, 0)
+	require.NoError(t, err)
+	assert.WithinDuration(t, state.StartedAt.Add(time.Hour), state.ExpiresAt, time.Millisecond)
+}
+
+func TestServer_StartMaxRetries(t *testing.T) {
+	d := startServer(t)
+
+	state, err := d.client.Start("billing", 0, 0)
+	require.NoError(t, err)
+	process, err := os.FindProcess(state.PID)
+	require.NoError(t, err)
+	require.NoError(t, process.Kill())
+
+	// Without retries the proxy is given up on as soon as it exits
+	require.Eventually(t, func() bool {
+		state, _ := d.repo.Find("billing")
+		return state != nil && state.Phase == proxy.PhaseFailed
+	}, 5*time.Second, 10*time.Millisecond)
+	state, _ = d.repo.Find("billing")
+	assert.Zero(t, state.Restarts)
+}
+
+func TestServer_StartUnknownConfig(t *testing.T) {
+	client := startServer(t).client
+
+	_, err := client.Start("orders", 0, 0)
+
+	assert.EqualError(t, err, `config "orders" not found`)
+}
+
+func TestServer_StopsProxiesOnShutdown(t *testing.T) {
+	d := startServer(t)
+	client, repo, param := d.client, d.repo, d.param
+	_, err := client.Start("billing", 0, 0)
+	require.NoError(t, err)
+
+	d.shutdown()
+
+	assert.Empty(t, repo.states)
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(param.Port)))
+	require.NoError(t, err)
+	l.Close()
+}
+
+func TestServer_Logs(t *testing.T) {
+	d := startServer(t)
+	client, param := d.client, d.param
+
+	_, err := client.Logs("billing", 0)
+	assert.EqualError(t, err, `no logs for "billing"`)
+
+	state, err := client.Start("billing", 0, 0)
+	require.NoError(t, err)
+	// The proxy listens behind the forwarder on the config's port
+	assert.Equal(t, param.Port, state.Port)
+	want := fmt.Sprintf("Listening on 127.0.0.1:%d", state.ProxyPort)
+	require.Eventually(t, func() bool {
+		lines, err := client.Logs("billing", 1)
+		return err == nil && len(lines) == 1 && lines[0] == want
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The output is written to the log file too
+	var lines []string
+	err = d.logs.Tail(context.Background(), "billing", time.Time{}, false, func(line logs.Line) {
+		lines = append(lines, line.Text)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{want}, lines)
+}
+
+func TestServer_Subscribe(t *testing.T) {
+	d := startServer(t)
+	client, param := d.client, d.param
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := client.Subscribe(ctx)
+	require.NoError(t, err)
+	// Let the server register the subscriber before anything happens
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = client.Start("billing", 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, client.Stop("billing"))
+
+	var got []EventType
+	timeout := time.After(5 * time.Second)
+	for len(got) < 3 {
+		select {
+		case event := <-events:
+			assert.Equal(t, "billing", event.Name)
+			got = append(got, event.Type)
+			if event.Type == EventState {
+				assert.Equal(t, param.Port, event.State.Port)
+			}
+		case <-timeout:
+			t.Fatalf("received only %v", got)
+		}
+	}
+	assert.Contains(t, got, EventState)
+	assert.Contains(t, got, EventLog)
+	assert.Contains(t, got, EventStopped)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestClient_RemoteError(t *testing.T) {
+	resp := errorResponse(fmt.Errorf("start: %w", &RemoteError{Message: "credentials expired", ErrCode: "AUTH_REQUIRED"}))
+	assert.Equal(t, Response{Error: "start: credentials expired", Code: "AUTH_REQUIRED"}, resp)
+
+	assert.Equal(t, Response{Error: "boom"}, errorResponse(errors.New("boom")))
+}
+
+func TestSocketAvailable(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "tsunagi.sock")
+	assert.False(t, SocketAvailable(socketPath))
+
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	assert.True(t, SocketAvailable(socketPath))
+	l.Close()
+}
+
+func TestLogBuffer(t *testing.T) {
+	var published []string
+	buf := &logBuffer{onLine: func(line string) { published = append(published, line) }}
+
+	buf.Write([]byte("first\nsec"))
+	buf.Write([]byte("ond\n"))
+
+	assert.Equal(t, []string{"first", "second"}, buf.Tail(0))
+	assert.Equal(t, []string{"second"}, buf.Tail(1))
+	assert.Equal(t, []string{"first", "second"}, published)
+}
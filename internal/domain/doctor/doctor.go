@@ -0,0 +1,236 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+type Result struct {
+	Check   string
+	Status  Status
+	Message string
+	Hint    string `json:",omitempty"`
+}
+
+// File is a file or directory whose permissions are checked.
+// Forbidden holds the permission bits that must not be set.
+type File struct {
+	Path      string
+	Forbidden os.FileMode
+}
+
+type Doctor struct {
+	configs  config.Repository
+	states   proxy.Repository
+	proxy    *proxy.Proxy
+	proxyErr error
+	files    []File
+}
+
+func NewDoctor(configs config.Repository, states proxy.Repository, p *proxy.Proxy, files ...File) *Doctor {
+	return &Doctor{configs: configs, states: states, proxy: p, files: files}
+}
+
+// SetProxyError reports that the proxy service could not be set up, for example because the
+// cloud-sql-proxy selected with `proxy use` could not be read. The proxy check fails with it.
+func (d *Doctor) SetProxyError(err error) {
+	d.proxyErr = err
+}
+
+// Run runs all checks and returns their results.
+func (d *Doctor) Run() []Result {
+	var results []Result
+	results = append(results, d.checkGcloud()...)
+	results = append(results, d.checkProxy())
+	params, result := d.checkConfig()
+	results = append(results, result)
+	if params != nil {
+		results = append(results, checkDuplicates(params)...)
+	}
+	running, stale := d.checkStates()
+	results = append(results, stale...)
+	if params != nil {
+		results = append(results, checkPorts(params, running)...)
+	}
+	results = append(results, d.checkFiles()...)
+	return results
+}
+
+// Failed reports whether any of the results failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Doctor) checkGcloud() []Result {
+	version, err := utils.GetGcloudVersion()
+	if err != nil {
+		return []Result{
+			{Check: "gcloud", Status: StatusFail, Message: "gcloud command not found", Hint: "install the Google Cloud SDK: https://cloud.google.com/sdk/docs/install"},
+			{Check: "credentials", Status: StatusWarn, Message: "skipped because gcloud is not installed"},
+		}
+	}
+	results := []Result{{Check: "gcloud", Status: StatusPass, Message: version}}
+
+	err = utils.CheckGcloudAuth(utils.GcloudContext{})
+	var authErr *utils.AuthError
+	switch {
+	case errors.As(err, &authErr):
+		results = append(results, Result{Check: "credentials", Status: StatusFail, Message: "Application Default Credentials are missing or expired", Hint: "run: " + authErr.LoginCommand()})
+	case err != nil:
+		results = append(results, Result{Check: "credentials", Status: StatusFail, Message: err.Error()})
+	default:
+		results = append(results, Result{Check: "credentials", Status: StatusPass, Message: "Application Default Credentials are valid"})
+	}
+	return results
+}
+
+func (d *Doctor) checkProxy() Result {
+	if d.proxyErr != nil {
+		return Result{Check: "cloud-sql-proxy", Status: StatusFail, Message: "cloud-sql-proxy could not be selected: " + d.proxyErr.Error(), Hint: "select an installed version with tsunagi proxy use"}
+	}
+	version, err := d.proxy.Version()
+	if err != nil && !errors.Is(err, exec.ErrNotFound) {
+		return Result{Check: "cloud-sql-proxy", Status: StatusFail, Message: "cloud-sql-proxy --version failed: " + err.Error(), Hint: "reinstall the Cloud SQL Auth Proxy"}
+	}
+	if err != nil {
+		return Result{Check: "cloud-sql-proxy", Status: StatusFail, Message: "cloud-sql-proxy not found", Hint: "install the Cloud SQL Auth Proxy: https://cloud.google.com/sql/docs/postgres/connect-auth-proxy#install"}
+	}
+	return Result{Check: "cloud-sql-proxy", Status: StatusPass, Message: version}
+}
+
+func (d *Doctor) checkConfig() ([]config.ConfigParam, Result) {
+	params, err := d.configs.FindAll()
+	if err != nil {
+		return nil, Result{Check: "config", Status: StatusFail, Message: "config file could not be read: " + err.Error(), Hint: "fix or remove the config file"}
+	}
+	return params, Result{Check: "config", Status: StatusPass, Message: fmt.Sprintf("%d configs saved", len(params))}
+}
+
+func checkDuplicates(params []config.ConfigParam) []Result {
+	names := map[string]int{}
+	ports := map[int][]string{}
+	for _, param := range params {
+		names[param.Name]++
+		ports[param.Port] = append(ports[param.Port], param.Name)
+	}
+
+	var results []Result
+	for _, name := range sortedKeys(names) {
+		if names[name] > 1 {
+			results = append(results, Result{Check: "duplicates", Status: StatusFail, Message: fmt.Sprintf("config name %q is used %d times", name, names[name]), Hint: "remove or rename the duplicated configs"})
+		}
+	}
+	for _, port := range sortedKeys(ports) {
+		if len(ports[port]) > 1 {
+			results = append(results, Result{Check: "duplicates", Status: StatusWarn, Message: fmt.Sprintf("port %d is shared by %s", port, strings.Join(ports[port], ", ")), Hint: "these proxies cannot run at the same time, give each config its own port"})
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, Result{Check: "duplicates", Status: StatusPass, Message: "config names and ports are unique"})
+	}
+	return results
+}
+
+// checkStates returns the names of running proxies and reports state files of proxies that are gone.
+// The states of proxies tsunagi stopped are kept on purpose, and proxies it gave up on are reported
+// with the reason.
+func (d *Doctor) checkStates() (map[string]bool, []Result) {
+	states, err := d.states.FindAll()
+	if err != nil {
+		return nil, []Result{{Check: "state", Status: StatusFail, Message: "proxy state could not be read: " + err.Error()}}
+	}
+
+	running := map[string]bool{}
+	var results []Result
+	for _, state := range states {
+		switch state.Phase {
+		case proxy.PhaseStopped:
+			continue
+		case proxy.PhaseFailed:
+			results = append(results, Result{Check: "state", Status: StatusWarn, Message: fmt.Sprintf("last run of %q failed: %s", state.Name, state.LastExit), Hint: fmt.Sprintf("see tsunagi logs %s, then start it again", state.Name)})
+			continue
+		}
+		if state.Alive() {
+			running[state.Name] = true
+			continue
+		}
+		results = append(results, Result{Check: "state", Status: StatusWarn, Message: fmt.Sprintf("state of %q refers to pid %d which is not running", state.Name, state.PID), Hint: fmt.Sprintf("it is removed by the next proxyStart or proxyStop of %q", state.Name)})
+	}
+	if len(results) == 0 {
+		results = append(results, Result{Check: "state", Status: StatusPass, Message: fmt.Sprintf("%d proxies running", len(running))})
+	}
+	return running, results
+}
+
+func checkPorts(params []config.ConfigParam, running map[string]bool) []Result {
+	var results []Result
+	for _, param := range params {
+		if running[param.Name] {
+			continue
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(param.Port)))
+		if err != nil {
+			results = append(results, Result{Check: "ports", Status: StatusWarn, Message: fmt.Sprintf("port %d of %q is in use by another process", param.Port, param.Name), Hint: "stop the other process or change the port of the config"})
+			continue
+		}
+		l.Close()
+	}
+	if len(results) == 0 {
+		results = append(results, Result{Check: "ports", Status: StatusPass, Message: "ports of stopped configs are free"})
+	}
+	return results
+}
+
+func (d *Doctor) checkFiles() []Result {
+	var results []Result
+	for _, file := range d.files {
+		info, err := os.Stat(file.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			results = append(results, Result{Check: "permissions", Status: StatusFail, Message: err.Error()})
+			continue
+		}
+		perm := info.Mode().Perm()
+		if perm&file.Forbidden != 0 {
+			results = append(results, Result{Check: "permissions", Status: StatusWarn, Message: fmt.Sprintf("%s has mode %04o", file.Path, perm), Hint: fmt.Sprintf("run: chmod %04o %s", perm&^file.Forbidden, file.Path)})
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, Result{Check: "permissions", Status: StatusPass, Message: "file permissions are restricted"})
+	}
+	return results
+}
+
+func sortedKeys[K string | int, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
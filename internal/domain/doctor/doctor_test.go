@@ -0,0 +1,218 @@
+package doctor
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockConfigRepository struct {
+	params []config.ConfigParam
+	err    error
+}
+
+func (m *mockConfigRepository) Save(param config.ConfigParam) error   { return nil }
+func (m *mockConfigRepository) Update(param config.ConfigParam) error { return nil }
+func (m *mockConfigRepository) FindAll() ([]config.ConfigParam, error) {
+	return m.params, m.err
+}
+
+type mockStateRepository struct {
+	states []proxy.State
+}
+
+func (m *mockStateRepository) Save(state proxy.State) error { return nil }
+func (m *mockStateRepository) Find(name string) (*proxy.State, error) {
+	return nil, nil
+}
+func (m *mockStateRepository) FindAll() ([]proxy.State, error) { return m.states, nil }
+func (m *mockStateRepository) Delete(name string) error        { return nil }
+func (m *mockStateRepository) SaveExpiry(name string, expiresAt time.Time) error {
+	return nil
+}
+func (m *mockStateRepository) FindExpiry(name string) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+// setupFakeCommands puts fake gcloud and cloud-sql-proxy commands on PATH.
+func setupFakeCommands(t *testing.T, gcloud string, proxy string) {
+	t.Helper()
+	dir := t.TempDir()
+	if gcloud != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "gcloud"), []byte("#!/bin/sh\n"+gcloud+"\n"), 0755))
+	}
+	if proxy != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "cloud-sql-proxy"), []byte("#!/bin/sh\n"+proxy+"\n"), 0755))
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+"/bin:/usr/bin")
+}
+
+func resultsOf(results []Result, check string) []Result {
+	var filtered []Result
+	for _, r := range results {
+		if r.Check == check {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+func deadPID(t *testing.T) int {
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+func TestDoctor_Run_AllPass(t *testing.T) {
+	setupFakeCommands(t,
+		`[ "$1" = "--version" ] && echo "Google Cloud SDK 470.0.0"; exit 0`,
+		`echo "cloud-sql-proxy version 2.14.0"`,
+	)
+	configFile := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(configFile, []byte("[]"), 0644))
+
+	configs := &mockConfigRepository{params: []config.ConfigParam{{Name: "config1", Port: 0}}}
+	states := &mockStateRepository{}
+	d := NewDoctor(configs, states, proxy.NewProxy(states), File{Path: configFile, Forbidden: 0022})
+
+	results := d.Run()
+
+	for _, r := range results {
+		assert.Equal(t, StatusPass, r.Status, "%s: %s", r.Check, r.Message)
+	}
+	assert.Equal(t, "Google Cloud SDK 470.0.0", resultsOf(results, "gcloud")[0].Message)
+	assert.Equal(t, "cloud-sql-proxy version 2.14.0", resultsOf(results, "cloud-sql-proxy")[0].Message)
+	assert.False(t, Failed(results))
+}
+
+func TestDoctor_Run_MissingCommands(t *testing.T) {
+	setupFakeCommands(t, "", "")
+
+	states := &mockStateRepository{}
+	d := NewDoctor(&mockConfigRepository{}, states, proxy.NewProxy(states))
+
+	results := d.Run()
+
+	assert.Equal(t, StatusFail, resultsOf(results, "gcloud")[0].Status)
+	assert.Equal(t, StatusWarn, resultsOf(results, "credentials")[0].Status)
+	assert.Equal(t, StatusFail, resultsOf(results, "cloud-sql-proxy")[0].Status)
+	assert.NotEmpty(t, resultsOf(results, "cloud-sql-proxy")[0].Hint)
+	assert.True(t, Failed(results))
+}
+
+func TestDoctor_Run_ExpiredCredentials(t *testing.T) {
+	setupFakeCommands(t, `[ "$1" = "--version" ] && echo "Google Cloud SDK 470.0.0" && exit 0; exit 1`, "")
+
+	states := &mockStateRepository{}
+	d := NewDoctor(&mockConfigRepository{}, states, proxy.NewProxy(states))
+
+	credentials := resultsOf(d.Run(), "credentials")[0]
+
+	assert.Equal(t, StatusFail, credentials.Status)
+	assert.Equal(t, "run: gcloud auth application-default login", credentials.Hint)
+}
+
+func TestDoctor_Run_ConfigError(t *testing.T) {
+	setupFakeCommands(t, "", "")
+
+	states := &mockStateRepository{}
+	d := NewDoctor(&mockConfigRepository{err: errors.New("invalid character")}, states, proxy.NewProxy(states))
+
+	results := d.Run()
+
+	assert.Equal(t, StatusFail, resultsOf(results, "config")[0].Status)
+	assert.Empty(t, resultsOf(results, "duplicates"))
+	assert.Empty(t, resultsOf(results, "ports"))
+}
+
+func TestCheckDuplicates(t *testing.T) {
+	results := checkDuplicates([]config.ConfigParam{
+		{Name: "billing", Port: 50001},
+		{Name: "billing", Port: 50002},
+		{Name: "orders", Port: 50002},
+	})
+
+	require.Len(t, results, 2)
+	assert.Equal(t, Result{Check: "duplicates", Status: StatusFail, Message: `config name "billing" is used 2 times`, Hint: "remove or rename the duplicated configs"}, results[0])
+	assert.Equal(t, StatusWarn, results[1].Status)
+	assert.Equal(t, "port 50002 is shared by billing, orders", results[1].Message)
+}
+
+func TestCheckPorts(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	params := []config.ConfigParam{{Name: "billing", Port: port}}
+
+	results := checkPorts(params, map[string]bool{})
+	assert.Equal(t, StatusWarn, results[0].Status)
+
+	// The port of a running proxy is expected to be in use
+	results = checkPorts(params, map[string]bool{"billing": true})
+	assert.Equal(t, StatusPass, results[0].Status)
+}
+
+func TestDoctor_checkStates(t *testing.T) {
+	states := &mockStateRepository{states: []proxy.State{
+		{Name: "running", PID: os.Getpid()},
+		{Name: "stale", PID: deadPID(t)},
+		{Name: "idle", PID: deadPID(t), Phase: proxy.PhaseStopped, LastExit: "stopped after being idle for 30m0s"},
+		{Name: "gave-up", PID: deadPID(t), Phase: proxy.PhaseFailed, LastExit: "exit status 1: connection refused"},
+	}}
+	d := NewDoctor(&mockConfigRepository{}, states, proxy.NewProxy(states))
+
+	running, results := d.checkStates()
+
+	assert.Equal(t, map[string]bool{"running": true}, running)
+	require.Len(t, results, 2)
+	assert.Equal(t, StatusWarn, results[0].Status)
+	assert.Contains(t, results[0].Message, `state of "stale"`)
+	// States kept to tell why a proxy stopped are not stale
+	assert.Equal(t, StatusWarn, results[1].Status)
+	assert.Equal(t, `last run of "gave-up" failed: exit status 1: connection refused`, results[1].Message)
+}
+
+func TestDoctor_Run_ProxyError(t *testing.T) {
+	setupFakeCommands(t, "", "")
+	states := &mockStateRepository{}
+	d := NewDoctor(&mockConfigRepository{}, states, nil)
+	d.SetProxyError(errors.New("cloud-sql-proxy v2.14.0 is not installed"))
+
+	results := d.Run()
+
+	result := resultsOf(results, "cloud-sql-proxy")[0]
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Equal(t, "cloud-sql-proxy could not be selected: cloud-sql-proxy v2.14.0 is not installed", result.Message)
+	// The other checks still run
+	assert.NotEmpty(t, resultsOf(results, "config"))
+}
+
+func TestDoctor_checkFiles(t *testing.T) {
+	dir := t.TempDir()
+	secrets := filepath.Join(dir, "secrets")
+	require.NoError(t, os.WriteFile(secrets, []byte("{}"), 0644))
+	require.NoError(t, os.Chmod(secrets, 0644))
+
+	d := NewDoctor(nil, nil, nil,
+		File{Path: secrets, Forbidden: 0077},
+		File{Path: filepath.Join(dir, "missing"), Forbidden: 0077},
+	)
+
+	results := d.checkFiles()
+
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusWarn, results[0].Status)
+	assert.Equal(t, secrets+" has mode 0644", results[0].Message)
+	assert.Equal(t, "run: chmod 0600 "+secrets, results[0].Hint)
+}
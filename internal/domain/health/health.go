@@ -0,0 +1,198 @@
+package health
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+)
+
+// Level is how far a running proxy was found to work.
+type Level string
+
+const (
+	LevelRunning   Level = "running"
+	LevelListening Level = "listening"
+	LevelReachable Level = "database reachable"
+)
+
+const localHost = "127.0.0.1"
+
+// postgresProtocolVersion is protocol version 3.0 as sent in the startup message.
+const postgresProtocolVersion = 3 << 16
+
+// Report is the result of checking a running proxy.
+// Ready and DatabaseReachable are nil when the check was not run.
+type Report struct {
+	Listening         bool
+	Ready             *bool  `json:",omitempty"`
+	DatabaseReachable *bool  `json:",omitempty"`
+	Error             string `json:",omitempty"`
+}
+
+// Level returns the level the checks reached.
+func (r Report) Level() Level {
+	if !r.Listening || (r.Ready != nil && !*r.Ready) {
+		return LevelRunning
+	}
+	if r.DatabaseReachable != nil && *r.DatabaseReachable {
+		return LevelReachable
+	}
+	return LevelListening
+}
+
+type Checker struct {
+	timeout time.Duration
+	client  *http.Client
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Check dials the proxy's port, queries its readiness endpoint when the config has a health
+// check port and, when ping is true, runs a protocol level ping of the database.
+// It stops at the first check that fails and records its error.
+func (c *Checker) Check(param config.ConfigParam, ping bool) Report {
+	var report Report
+	conn, err := c.dial(param.Port)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	conn.Close()
+	report.Listening = true
+
+	if param.HealthCheckPort != 0 {
+		err = c.ready(param.HealthCheckPort)
+		report.Ready = boolPtr(err == nil)
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+	}
+
+	if ping && pingSupported(param.Engine) {
+		err = c.Ping(param)
+		report.DatabaseReachable = boolPtr(err == nil)
+		if err != nil {
+			report.Error = err.Error()
+		}
+	}
+	return report
+}
+
+func (c *Checker) dial(port int) (net.Conn, error) {
+	return net.DialTimeout("tcp", net.JoinHostPort(localHost, strconv.Itoa(port)), c.timeout)
+}
+
+// ready queries the readiness endpoint cloud-sql-proxy serves with --health-check.
+func (c *Checker) ready(port int) error {
+	resp, err := c.client.Get(fmt.Sprintf("http://%s/readiness", net.JoinHostPort(localHost, strconv.Itoa(port))))
+	if err != nil {
+		return fmt.Errorf("health check endpoint is not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy is not ready: %s", resp.Status)
+	}
+	return nil
+}
+
+func pingSupported(engine cloud.Engine) bool {
+	return engine == cloud.EnginePostgres || engine == cloud.EngineMySQL
+}
+
+// Ping checks that the database answers through the proxy without logging in.
+// Any answer from the server, including an authentication error, counts as reachable.
+func (c *Checker) Ping(param config.ConfigParam) error {
+	if !pingSupported(param.Engine) {
+		return fmt.Errorf("ping does not support engine %q", param.Engine)
+	}
+	conn, err := c.dial(param.Port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if param.Engine == cloud.EnginePostgres {
+		err = pingPostgres(conn, param)
+	} else {
+		err = pingMySQL(conn)
+	}
+	if err != nil {
+		return fmt.Errorf("database did not respond: %w", err)
+	}
+	return nil
+}
+
+// pingPostgres sends a startup message and expects an authentication request or an error.
+func pingPostgres(conn net.Conn, param config.ConfigParam) error {
+	user := param.User
+	if user == "" {
+		user = "tsunagi"
+	}
+	database := param.Database
+	if database == "" {
+		database = user
+	}
+
+	body := binary.BigEndian.AppendUint32(nil, postgresProtocolVersion)
+	for _, s := range []string{"user", user, "database", database} {
+		body = append(append(body, s...), 0)
+	}
+	body = append(body, 0)
+	msg := binary.BigEndian.AppendUint32(nil, uint32(len(body)+4))
+	_, err := conn.Write(append(msg, body...))
+	if err != nil {
+		return err
+	}
+
+	reply := make([]byte, 1)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return err
+	}
+	if reply[0] != 'R' && reply[0] != 'E' {
+		return errors.New("unexpected response")
+	}
+	return nil
+}
+
+// pingMySQL reads the initial handshake the server sends on connect.
+func pingMySQL(conn net.Conn) error {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		return err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if length == 0 {
+		return errors.New("unexpected response")
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(conn, payload)
+	if err != nil {
+		return err
+	}
+	// Protocol version 10 handshake, or an error packet
+	if payload[0] != 10 && payload[0] != 0xff {
+		return errors.New("unexpected response")
+	}
+	return nil
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
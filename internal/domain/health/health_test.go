@@ -0,0 +1,176 @@
+package health
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve listens on a free local port and handles every connection with handle.
+func serve(t *testing.T, handle func(conn net.Conn)) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// fakePostgres reads a startup message and answers with reply.
+func fakePostgres(t *testing.T, reply byte, gotStartup chan<- []byte) int {
+	return serve(t, func(conn net.Conn) {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header)-4)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		if gotStartup != nil {
+			gotStartup <- body
+		}
+		conn.Write([]byte{reply, 0, 0, 0, 8, 0, 0, 0, 3})
+	})
+}
+
+func fakeMySQL(t *testing.T) int {
+	return serve(t, func(conn net.Conn) {
+		payload := append([]byte{10}, "8.0.31-google\x00"...)
+		conn.Write(append([]byte{byte(len(payload)), 0, 0, 0}, payload...))
+	})
+}
+
+// closedPort returns a port nothing listens on.
+func closedPort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func readinessServer(t *testing.T, status int) int {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/readiness", r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+func TestChecker_Check(t *testing.T) {
+	checker := NewChecker(time.Second)
+
+	t.Run("not listening", func(t *testing.T) {
+		report := checker.Check(config.ConfigParam{Port: closedPort(t)}, true)
+		assert.False(t, report.Listening)
+		assert.NotEmpty(t, report.Error)
+		assert.Equal(t, LevelRunning, report.Level())
+	})
+
+	t.Run("listening", func(t *testing.T) {
+		report := checker.Check(config.ConfigParam{Port: serve(t, func(net.Conn) {})}, false)
+		assert.Equal(t, Report{Listening: true}, report)
+		assert.Equal(t, LevelListening, report.Level())
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		param := config.ConfigParam{Port: serve(t, func(net.Conn) {}), HealthCheckPort: readinessServer(t, http.StatusOK)}
+		report := checker.Check(param, false)
+		require.NotNil(t, report.Ready)
+		assert.True(t, *report.Ready)
+		assert.Equal(t, LevelListening, report.Level())
+	})
+
+	t.Run("not ready", func(t *testing.T) {
+		param := config.ConfigParam{Port: serve(t, func(net.Conn) {}), HealthCheckPort: readinessServer(t, http.StatusServiceUnavailable)}
+		report := checker.Check(param, true)
+		require.NotNil(t, report.Ready)
+		assert.False(t, *report.Ready)
+		assert.Nil(t, report.DatabaseReachable)
+		assert.Equal(t, "proxy is not ready: 503 Service Unavailable", report.Error)
+		assert.Equal(t, LevelRunning, report.Level())
+	})
+
+	t.Run("database reachable", func(t *testing.T) {
+		param := config.ConfigParam{Port: fakePostgres(t, 'R', nil), Engine: cloud.EnginePostgres}
+		report := checker.Check(param, true)
+		require.NotNil(t, report.DatabaseReachable)
+		assert.True(t, *report.DatabaseReachable)
+		assert.Equal(t, LevelReachable, report.Level())
+	})
+
+	t.Run("database unreachable", func(t *testing.T) {
+		// The proxy accepts the connection but closes it when it cannot reach the instance
+		param := config.ConfigParam{Port: serve(t, func(net.Conn) {}), Engine: cloud.EngineMySQL}
+		report := checker.Check(param, true)
+		require.NotNil(t, report.DatabaseReachable)
+		assert.False(t, *report.DatabaseReachable)
+		assert.Equal(t, "database did not respond: EOF", report.Error)
+		assert.Equal(t, LevelListening, report.Level())
+	})
+
+	t.Run("ping not supported", func(t *testing.T) {
+		param := config.ConfigParam{Port: serve(t, func(net.Conn) {}), Engine: cloud.EngineSQLServer}
+		report := checker.Check(param, true)
+		assert.Nil(t, report.DatabaseReachable)
+		assert.Equal(t, LevelListening, report.Level())
+	})
+}
+
+func TestChecker_Ping(t *testing.T) {
+	checker := NewChecker(time.Second)
+
+	t.Run("postgres", func(t *testing.T) {
+		startup := make(chan []byte, 1)
+		param := config.ConfigParam{Port: fakePostgres(t, 'R', startup), Engine: cloud.EnginePostgres, User: "app", Database: "billing"}
+		require.NoError(t, checker.Ping(param))
+		body := <-startup
+		assert.Equal(t, uint32(196608), binary.BigEndian.Uint32(body))
+		assert.Equal(t, "user\x00app\x00database\x00billing\x00\x00", string(body[4:]))
+	})
+
+	t.Run("postgres error counts as reachable", func(t *testing.T) {
+		param := config.ConfigParam{Port: fakePostgres(t, 'E', nil), Engine: cloud.EnginePostgres}
+		assert.NoError(t, checker.Ping(param))
+	})
+
+	t.Run("postgres unexpected response", func(t *testing.T) {
+		param := config.ConfigParam{Port: fakePostgres(t, 'Z', nil), Engine: cloud.EnginePostgres}
+		assert.EqualError(t, checker.Ping(param), "database did not respond: unexpected response")
+	})
+
+	t.Run("mysql", func(t *testing.T) {
+		param := config.ConfigParam{Port: fakeMySQL(t), Engine: cloud.EngineMySQL}
+		assert.NoError(t, checker.Ping(param))
+	})
+
+	t.Run("unsupported engine", func(t *testing.T) {
+		param := config.ConfigParam{Port: fakeMySQL(t), Engine: cloud.EngineSQLServer}
+		assert.EqualError(t, checker.Ping(param), `ping does not support engine "sqlserver"`)
+	})
+}
@@ -0,0 +1,287 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeLayout is the layout of the timestamp tsunagi adds to each line.
+const timeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// Rotation controls when the log of a proxy is rotated and how many rotated files are kept.
+type Rotation struct {
+	MaxSize int64
+	Keep    int
+}
+
+func DefaultRotation() Rotation {
+	return Rotation{
+		MaxSize: 10 * 1024 * 1024,
+		Keep:    3,
+	}
+}
+
+// Line is a line of a proxy's output with the time tsunagi received it.
+type Line struct {
+	Time time.Time
+	Text string
+}
+
+func (l Line) String() string {
+	return l.Time.Format(timeLayout) + " " + l.Text
+}
+
+func parseLine(s string) (Line, bool) {
+	stamp, text, ok := strings.Cut(s, " ")
+	if !ok {
+		return Line{Text: s}, false
+	}
+	t, err := time.Parse(timeLayout, stamp)
+	if err != nil {
+		return Line{Text: s}, false
+	}
+	return Line{Time: t, Text: text}, true
+}
+
+// Store keeps the output of each proxy in a log file named after its config, <name>.log,
+// rotated to <name>.log.1, <name>.log.2 and so on when it grows beyond the rotation's size.
+type Store struct {
+	dir          string
+	rotation     Rotation
+	now          func() time.Time
+	pollInterval time.Duration
+}
+
+func NewStore(dir string, rotation Rotation) *Store {
+	return &Store{
+		dir:          dir,
+		rotation:     rotation,
+		now:          time.Now,
+		pollInterval: 200 * time.Millisecond,
+	}
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".log")
+}
+
+func (s *Store) rotatedPath(name string, n int) string {
+	return s.path(name) + "." + strconv.Itoa(n)
+}
+
+// Writer returns a writer appending the output written to it to the log of name,
+// one timestamped line at a time.
+func (s *Store) Writer(name string) (*Writer, error) {
+	err := os.MkdirAll(s.dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{s: s, name: name}
+	err = w.open()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Tail calls f with the lines of the log of name received at or after since, oldest first,
+// including the rotated files. With follow it keeps calling f with new lines until ctx is done.
+func (s *Store) Tail(ctx context.Context, name string, since time.Time, follow bool, f func(Line)) error {
+	// The current file is opened first so that a rotation cannot make lines go missing
+	file, err := os.Open(s.path(name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no logs for %q", name)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+
+	t := &tail{since: since, f: f}
+	for n := s.rotation.Keep; n >= 1; n-- {
+		rotated, err := os.Open(s.rotatedPath(name, n))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = t.read(bufio.NewReader(rotated))
+		rotated.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		err = t.read(reader)
+		if err != nil || !follow {
+			return err
+		}
+		if s.rotated(file, name) {
+			next, err := os.Open(s.path(name))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err == nil {
+				// Lines written to the old file right before it was rotated
+				err = t.read(reader)
+				file.Close()
+				if err != nil {
+					next.Close()
+					return err
+				}
+				file = next
+				reader.Reset(file)
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+// rotated reports whether the log file of name is no longer the open file.
+func (s *Store) rotated(file *os.File, name string) bool {
+	current, err := os.Stat(s.path(name))
+	if err != nil {
+		return false
+	}
+	open, err := file.Stat()
+	return err != nil || !os.SameFile(current, open)
+}
+
+// tail passes the lines read since the time it is interested in to f.
+type tail struct {
+	since   time.Time
+	f       func(Line)
+	partial string
+	last    time.Time
+}
+
+// read reads lines until the end of r. A line without a newline yet is kept until it is completed.
+func (t *tail) read(r *bufio.Reader) error {
+	for {
+		s, err := r.ReadString('\n')
+		if err == io.EOF {
+			t.partial += s
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, ok := parseLine(strings.TrimSuffix(t.partial+s, "\n"))
+		t.partial = ""
+		if ok {
+			t.last = line.Time
+		} else {
+			// Lines without a timestamp belong to the line before them
+			line.Time = t.last
+		}
+		if line.Time.Before(t.since) {
+			continue
+		}
+		t.f(line)
+	}
+}
+
+// Writer timestamps the lines written to it and appends them to a proxy's log, rotating it.
+// Errors writing the file are dropped so that a full disk does not block the proxy.
+type Writer struct {
+	mu      sync.Mutex
+	s       *Store
+	name    string
+	file    *os.File
+	size    int64
+	partial []byte
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.s.path(w.name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, b...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+	return len(b), nil
+}
+
+// Close writes an incomplete last line and closes the file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) > 0 {
+		w.writeLine(string(w.partial))
+		w.partial = nil
+	}
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
+
+func (w *Writer) writeLine(text string) {
+	data := Line{Time: w.s.now(), Text: strings.TrimSuffix(text, "\r")}.String() + "\n"
+	if w.size > 0 && w.size+int64(len(data)) > w.s.rotation.MaxSize {
+		w.rotate()
+	}
+	if w.file == nil {
+		return
+	}
+	n, _ := w.file.WriteString(data)
+	w.size += int64(n)
+}
+
+// rotate moves the current file to <name>.log.1, shifting the older ones and removing the
+// oldest, and starts a new file.
+func (w *Writer) rotate() {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	path := w.s.path(w.name)
+	keep := w.s.rotation.Keep
+	if keep < 1 {
+		os.Remove(path)
+	} else {
+		os.Remove(w.s.rotatedPath(w.name, keep))
+		for n := keep - 1; n >= 1; n-- {
+			os.Rename(w.s.rotatedPath(w.name, n), w.s.rotatedPath(w.name, n+1))
+		}
+		os.Rename(path, w.s.rotatedPath(w.name, 1))
+	}
+	w.open()
+}
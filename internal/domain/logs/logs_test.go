@@ -0,0 +1,138 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T, rotation Rotation) (*Store, *time.Time) {
+	t.Helper()
+	s := NewStore(filepath.Join(t.TempDir(), "logs"), rotation)
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	s.pollInterval = 10 * time.Millisecond
+	return s, &now
+}
+
+func tailAll(t *testing.T, s *Store, name string, since time.Time) []string {
+	t.Helper()
+	var lines []string
+	err := s.Tail(context.Background(), name, since, false, func(line Line) {
+		lines = append(lines, line.String())
+	})
+	require.NoError(t, err)
+	return lines
+}
+
+func TestWriter(t *testing.T) {
+	s, _ := newTestStore(t, DefaultRotation())
+
+	w, err := s.Writer("billing")
+	require.NoError(t, err)
+	w.Write([]byte("Listening on 127.0.0.1:50000\nThe proxy has sta"))
+	w.Write([]byte("rted successfully\r\n"))
+	w.Write([]byte("partial"))
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(filepath.Join(s.dir, "billing.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-02T03:04:05.000Z Listening on 127.0.0.1:50000\n"+
+		"2025-01-02T03:04:05.000Z The proxy has started successfully\n"+
+		"2025-01-02T03:04:05.000Z partial\n", string(data))
+	info, err := os.Stat(filepath.Join(s.dir, "billing.log"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestWriter_Rotate(t *testing.T) {
+	// Each line takes 32 bytes, so that a file holds two of them
+	s, _ := newTestStore(t, Rotation{MaxSize: 70, Keep: 2})
+
+	w, err := s.Writer("billing")
+	require.NoError(t, err)
+	for i := 1; i <= 7; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+	require.NoError(t, w.Close())
+
+	_, err = os.Stat(filepath.Join(s.dir, "billing.log.3"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, []string{
+		"2025-01-02T03:04:05.000Z line 3",
+		"2025-01-02T03:04:05.000Z line 4",
+		"2025-01-02T03:04:05.000Z line 5",
+		"2025-01-02T03:04:05.000Z line 6",
+		"2025-01-02T03:04:05.000Z line 7",
+	}, tailAll(t, s, "billing", time.Time{}))
+}
+
+func TestStore_Tail_Since(t *testing.T) {
+	s, now := newTestStore(t, DefaultRotation())
+	w, err := s.Writer("billing")
+	require.NoError(t, err)
+	w.Write([]byte("old\n"))
+	start := *now
+	*now = start.Add(time.Hour)
+	w.Write([]byte("new\n"))
+	require.NoError(t, w.Close())
+	// Lines without a timestamp, written by hand, belong to the line before them
+	file, err := os.OpenFile(filepath.Join(s.dir, "billing.log"), os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	file.WriteString("continued\n")
+	file.Close()
+
+	assert.Equal(t, []string{
+		"2025-01-02T04:04:05.000Z new",
+		"2025-01-02T04:04:05.000Z continued",
+	}, tailAll(t, s, "billing", start.Add(time.Minute)))
+}
+
+func TestStore_Tail_NoLogs(t *testing.T) {
+	s, _ := newTestStore(t, DefaultRotation())
+
+	err := s.Tail(context.Background(), "billing", time.Time{}, false, func(line Line) {})
+
+	assert.EqualError(t, err, `no logs for "billing"`)
+}
+
+func TestStore_Tail_Follow(t *testing.T) {
+	s, _ := newTestStore(t, Rotation{MaxSize: 70, Keep: 2})
+	w, err := s.Writer("billing")
+	require.NoError(t, err)
+	defer w.Close()
+	fmt.Fprintln(w, "line 1")
+
+	var mu sync.Mutex
+	var lines []string
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Tail(ctx, "billing", time.Time{}, true, func(line Line) {
+			mu.Lock()
+			lines = append(lines, line.Text)
+			mu.Unlock()
+		})
+	}()
+
+	// Following continues across rotations
+	for i := 2; i <= 5; i++ {
+		time.Sleep(30 * time.Millisecond)
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(lines) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"line 1", "line 2", "line 3", "line 4", "line 5"}, lines)
+}
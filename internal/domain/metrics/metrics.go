@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+)
+
+// ContentType is the content type of the Prometheus text exposition format written by Write.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type metricType string
+
+const (
+	gauge   metricType = "gauge"
+	counter metricType = "counter"
+)
+
+type family struct {
+	name  string
+	typ   metricType
+	help  string
+	value func(state *proxy.State, now time.Time) (float64, bool)
+}
+
+// families are the metrics written for each config. value returns false when the metric
+// has no sample for the config, such as connection counts of a proxy that is not running.
+var families = []family{
+	{
+		name: "tsunagi_proxy_up",
+		typ:  gauge,
+		help: "Whether the proxy of the config is running (1) or not (0).",
+		value: func(state *proxy.State, now time.Time) (float64, bool) {
+			if up(state) {
+				return 1, true
+			}
+			return 0, true
+		},
+	},
+	{
+		name: "tsunagi_proxy_restarts_total",
+		typ:  counter,
+		help: "Number of times the supervisor restarted the proxy.",
+		value: func(state *proxy.State, now time.Time) (float64, bool) {
+			if state == nil {
+				return 0, false
+			}
+			return float64(state.Restarts), true
+		},
+	},
+	{
+		name: "tsunagi_proxy_session_duration_seconds",
+		typ:  gauge,
+		help: "Time since the proxy was started.",
+		value: func(state *proxy.State, now time.Time) (float64, bool) {
+			if !up(state) {
+				return 0, false
+			}
+			return now.Sub(state.StartedAt).Seconds(), true
+		},
+	},
+	{
+		name:  "tsunagi_proxy_active_connections",
+		typ:   gauge,
+		help:  "Number of open client connections forwarded to the proxy.",
+		value: connections(func(stats *proxy.ConnectionStats) int64 { return int64(stats.Active) }),
+	},
+	{
+		name:  "tsunagi_proxy_connections_total",
+		typ:   counter,
+		help:  "Number of client connections forwarded to the proxy.",
+		value: connections(func(stats *proxy.ConnectionStats) int64 { return stats.Total }),
+	},
+	{
+		name:  "tsunagi_proxy_received_bytes_total",
+		typ:   counter,
+		help:  "Bytes received from clients and forwarded to the proxy.",
+		value: connections(func(stats *proxy.ConnectionStats) int64 { return stats.BytesIn }),
+	},
+	{
+		name:  "tsunagi_proxy_sent_bytes_total",
+		typ:   counter,
+		help:  "Bytes received from the proxy and sent to clients.",
+		value: connections(func(stats *proxy.ConnectionStats) int64 { return stats.BytesOut }),
+	},
+	{
+		name:  "tsunagi_proxy_connection_errors_total",
+		typ:   counter,
+		help:  "Number of client connections that could not be forwarded to the proxy.",
+		value: connections(func(stats *proxy.ConnectionStats) int64 { return stats.Errors }),
+	},
+}
+
+func up(state *proxy.State) bool {
+	return state != nil && state.Status() == proxy.PhaseRunning
+}
+
+func connections(value func(stats *proxy.ConnectionStats) int64) func(*proxy.State, time.Time) (float64, bool) {
+	return func(state *proxy.State, now time.Time) (float64, bool) {
+		if state == nil || state.Connections == nil {
+			return 0, false
+		}
+		return float64(value(state.Connections)), true
+	}
+}
+
+// Write writes the metrics of the configs and of the proxies with the states in the Prometheus
+// text exposition format. Configs without a state are reported as down.
+func Write(w io.Writer, configs []string, states []proxy.State, now time.Time) error {
+	byName := map[string]*proxy.State{}
+	for i := range states {
+		byName[states[i].Name] = &states[i]
+	}
+	names := slices.Clone(configs)
+	for name := range byName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+		for _, name := range names {
+			value, ok := f.value(byName[name], now)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(bw, "%s{config=\"%s\"} %s\n", f.name, escapeLabel(name), strconv.FormatFloat(value, 'g', -1, 64))
+		}
+	}
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// Handler serves the metrics of the configs and proxies returned by the functions.
+func Handler(configs func() ([]string, error), states func() ([]proxy.State, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names, err := configs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		list, err := states()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		Write(w, names, list, time.Now())
+	})
+}
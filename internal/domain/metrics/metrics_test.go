@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	states := []proxy.State{
+		{
+			Name:       "orders",
+			PID:        os.Getpid(),
+			Supervisor: os.Getpid(),
+			Phase:      proxy.PhaseRunning,
+			StartedAt:  now.Add(-90 * time.Second),
+			Restarts:   2,
+			Connections: &proxy.ConnectionStats{
+				Active:   1,
+				Total:    12,
+				BytesIn:  1024,
+				BytesOut: 4096,
+				Errors:   3,
+			},
+		},
+		{
+			Name:       "billing",
+			Supervisor: os.Getpid(),
+			Phase:      proxy.PhaseFailed,
+			Restarts:   5,
+		},
+	}
+	var out strings.Builder
+
+	err := Write(&out, []string{"billing", `report"s`, "orders"}, states, now)
+
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP tsunagi_proxy_up Whether the proxy of the config is running (1) or not (0).
+# TYPE tsunagi_proxy_up gauge
+tsunagi_proxy_up{config="billing"} 0
+tsunagi_proxy_up{config="orders"} 1
+tsunagi_proxy_up{config="report\"s"} 0
+# HELP tsunagi_proxy_restarts_total Number of times the supervisor restarted the proxy.
+# TYPE tsunagi_proxy_restarts_total counter
+tsunagi_proxy_restarts_total{config="billing"} 5
+tsunagi_proxy_restarts_total{config="orders"} 2
+# HELP tsunagi_proxy_session_duration_seconds Time since the proxy was started.
+# TYPE tsunagi_proxy_session_duration_seconds gauge
+tsunagi_proxy_session_duration_seconds{config="orders"} 90
+# HELP tsunagi_proxy_active_connections Number of open client connections forwarded to the proxy.
+# TYPE tsunagi_proxy_active_connections gauge
+tsunagi_proxy_active_connections{config="orders"} 1
+# HELP tsunagi_proxy_connections_total Number of client connections forwarded to the proxy.
+# TYPE tsunagi_proxy_connections_total counter
+tsunagi_proxy_connections_total{config="orders"} 12
+# HELP tsunagi_proxy_received_bytes_total Bytes received from clients and forwarded to the proxy.
+# TYPE tsunagi_proxy_received_bytes_total counter
+tsunagi_proxy_received_bytes_total{config="orders"} 1024
+# HELP tsunagi_proxy_sent_bytes_total Bytes received from the proxy and sent to clients.
+# TYPE tsunagi_proxy_sent_bytes_total counter
+tsunagi_proxy_sent_bytes_total{config="orders"} 4096
+# HELP tsunagi_proxy_connection_errors_total Number of client connections that could not be forwarded to the proxy.
+# TYPE tsunagi_proxy_connection_errors_total counter
+tsunagi_proxy_connection_errors_total{config="orders"} 3
+`, out.String())
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler(
+		func() ([]string, error) { return []string{"billing"}, nil },
+		func() ([]proxy.State, error) { return nil, nil },
+	)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "tsunagi_proxy_up{config=\"billing\"} 0\n")
+}
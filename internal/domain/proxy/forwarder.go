@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ConnectionStats counts the client connections forwarded to a proxy.
+type ConnectionStats struct {
+	Active int
+	Total  int64
+	// BytesIn is the number of bytes received from clients and BytesOut the number sent to them.
+	BytesIn  int64
+	BytesOut int64
+	// Errors is the number of connections that could not be forwarded to the proxy.
+	Errors int64
+	// LastActivity is when a connection was last opened, closed or transferred data.
+	LastActivity time.Time `json:",omitzero"`
+}
+
+// Forwarder accepts client connections on the config's port and forwards them to the proxy,
+// so that tsunagi knows how many connections are active and how much they transfer.
+type Forwarder struct {
+	l      net.Listener
+	target string
+
+	mu           sync.Mutex
+	stats        ConnectionStats
+	lastActivity time.Time
+}
+
+// Forward listens on addr and forwards connections to target until Close is called.
+func Forward(addr string, target string) (*Forwarder, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	f := &Forwarder{l: l, target: target, lastActivity: time.Now()}
+	go f.serve()
+	return f, nil
+}
+
+func (f *Forwarder) serve() {
+	for {
+		conn, err := f.l.Accept()
+		if err != nil {
+			return
+		}
+		go f.forward(conn)
+	}
+}
+
+func (f *Forwarder) forward(client net.Conn) {
+	f.track(func(stats *ConnectionStats) {
+		stats.Active++
+		stats.Total++
+	})
+	defer f.track(func(stats *ConnectionStats) { stats.Active-- })
+	defer client.Close()
+
+	upstream, err := net.DialTimeout("tcp", f.target, 10*time.Second)
+	if err != nil {
+		f.track(func(stats *ConnectionStats) { stats.Errors++ })
+		return
+	}
+	defer upstream.Close()
+
+	// Database protocols do not half-close, so the connection ends when either side closes
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(&countingWriter{w: upstream, count: func(n int64) {
+			f.track(func(stats *ConnectionStats) { stats.BytesIn += n })
+		}}, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(&countingWriter{w: client, count: func(n int64) {
+			f.track(func(stats *ConnectionStats) { stats.BytesOut += n })
+		}}, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// track updates the stats, recording the activity.
+func (f *Forwarder) track(update func(stats *ConnectionStats)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	update(&f.stats)
+	f.lastActivity = time.Now()
+	f.stats.LastActivity = f.lastActivity
+}
+
+// Active returns the number of open client connections.
+func (f *Forwarder) Active() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats.Active
+}
+
+// Stats returns the connection counts since the forwarder started.
+func (f *Forwarder) Stats() ConnectionStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// IdleFor returns how long there has been no client connection, or 0 while one is open.
+func (f *Forwarder) IdleFor() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stats.Active > 0 {
+		return 0
+	}
+	return time.Since(f.lastActivity)
+}
+
+// Close stops accepting connections. Open connections are left to finish.
+func (f *Forwarder) Close() error {
+	return f.l.Close()
+}
+
+// countingWriter passes the number of bytes written through it to count.
+type countingWriter struct {
+	w     io.Writer
+	count func(n int64)
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.count(int64(n))
+	return n, err
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoServer returns the address of a server writing back what it reads.
+func echoServer(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestForwarder(t *testing.T) {
+	addr := localAddress(freePort(t))
+	f, err := Forward(addr, echoServer(t))
+	require.NoError(t, err)
+	defer f.Close()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("ping\n"))
+	require.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "ping\n", line)
+	assert.Equal(t, 1, f.Active())
+	assert.Equal(t, time.Duration(0), f.IdleFor())
+	require.Eventually(t, func() bool {
+		stats := f.Stats()
+		stats.LastActivity = time.Time{}
+		return stats == ConnectionStats{Active: 1, Total: 1, BytesIn: 5, BytesOut: 5}
+	}, time.Second, 10*time.Millisecond)
+	assert.WithinDuration(t, time.Now(), f.Stats().LastActivity, time.Second)
+
+	conn.Close()
+	require.Eventually(t, func() bool { return f.Active() == 0 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.GreaterOrEqual(t, f.IdleFor(), 50*time.Millisecond)
+}
+
+func TestForwarder_TargetDown(t *testing.T) {
+	addr := localAddress(freePort(t))
+	f, err := Forward(addr, localAddress(freePort(t)))
+	require.NoError(t, err)
+	defer f.Close()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The client connection is closed when the proxy cannot be reached
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, int64(1), f.Stats().Errors)
+}
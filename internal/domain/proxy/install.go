@@ -0,0 +1,298 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// DefaultVersion is the cloud-sql-proxy version installed when none is given.
+const DefaultVersion = "v2.14.0"
+
+// DefaultDownloadURL is where cloud-sql-proxy releases are published.
+const DefaultDownloadURL = "https://storage.googleapis.com/cloud-sql-connectors/cloud-sql-proxy"
+
+// DefaultReleasesURL is the GitHub API of the cloud-sql-proxy releases, whose notes list the
+// SHA-256 sums of the release files.
+const DefaultReleasesURL = "https://api.github.com/repos/GoogleCloudPlatform/cloud-sql-proxy/releases"
+
+// SystemVersion selects the cloud-sql-proxy found on PATH.
+const SystemVersion = "system"
+
+const currentFile = "current"
+
+var versionRegex = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+
+var sha256Regex = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+var releaseNotesSHA256Regex = regexp.MustCompile(`\b[0-9a-fA-F]{64}\b`)
+
+// Installer manages cloud-sql-proxy releases downloaded into dirPath.
+// Each version is kept in its own directory and the file "current" holds the version in use.
+type Installer struct {
+	dirPath string
+	baseURL string
+	// checksumURL is the releases API whose release notes list the SHA-256 sums. It is
+	// separate from baseURL so that a mirror cannot vouch for the binaries it serves.
+	checksumURL string
+	client      *http.Client
+	goos        string
+	goarch      string
+}
+
+// NewInstaller returns an installer downloading releases from baseURL, defaulting to
+// DefaultDownloadURL, and reading their SHA-256 sums from the release notes at checksumURL,
+// defaulting to DefaultReleasesURL.
+func NewInstaller(dirPath string, baseURL string, checksumURL string) *Installer {
+	if baseURL == "" {
+		baseURL = DefaultDownloadURL
+	}
+	if checksumURL == "" {
+		checksumURL = DefaultReleasesURL
+	}
+	return &Installer{
+		dirPath:     dirPath,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		checksumURL: strings.TrimSuffix(checksumURL, "/"),
+		client:      http.DefaultClient,
+		goos:        runtime.GOOS,
+		goarch:      runtime.GOARCH,
+	}
+}
+
+// NormalizeVersion returns the version with a leading "v", or an error if it is not a release version.
+func NormalizeVersion(version string) (string, error) {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if !versionRegex.MatchString(version) {
+		return "", errors.New("version is not valid")
+	}
+	return version, nil
+}
+
+// assetName returns the name of the release file for the platform.
+func assetName(goos string, goarch string) (string, error) {
+	switch goos {
+	case "linux", "darwin":
+		switch goarch {
+		case "amd64", "arm64":
+			return fmt.Sprintf("%s.%s.%s", proxyBinary, goos, goarch), nil
+		case "386", "arm":
+			if goos == "linux" {
+				return fmt.Sprintf("%s.%s.%s", proxyBinary, goos, goarch), nil
+			}
+		}
+	case "windows":
+		switch goarch {
+		case "amd64":
+			return proxyBinary + ".x64.exe", nil
+		case "386":
+			return proxyBinary + ".x86.exe", nil
+		}
+	}
+	return "", fmt.Errorf("platform %s/%s is not supported", goos, goarch)
+}
+
+func (i *Installer) binaryPath(version string) string {
+	name := proxyBinary
+	if i.goos == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(i.dirPath, version, name)
+}
+
+// Install downloads the version for the current platform, verifies its checksum and makes it current.
+// The SHA-256 of the download is compared with wantSHA256 when given, and with the sum listed
+// in the release notes otherwise. Without a sum the version is not installed.
+func (i *Installer) Install(version string, wantSHA256 string) (string, error) {
+	version, err := NormalizeVersion(version)
+	if err != nil {
+		return "", err
+	}
+	asset, err := assetName(i.goos, i.goarch)
+	if err != nil {
+		return "", err
+	}
+	if wantSHA256 != "" && !sha256Regex.MatchString(wantSHA256) {
+		return "", errors.New("sha256 is not valid")
+	}
+
+	url := fmt.Sprintf("%s/%s/%s", i.baseURL, version, asset)
+	resp, err := i.client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("cloud-sql-proxy %s is not available for %s/%s", version, i.goos, i.goarch)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if wantSHA256 == "" {
+		wantSHA256, err = i.publishedSHA256(version, asset)
+		if err != nil {
+			return "", err
+		}
+	}
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), wantSHA256) {
+		return "", errors.New("checksum does not match, the download may be corrupted")
+	}
+
+	path := i.binaryPath(version)
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0755)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, i.Use(version)
+}
+
+// publishedSHA256 returns the SHA-256 sum of the release asset listed in the notes of the
+// release, read from the releases API at the checksum URL. The notes have a line per file with
+// its name and sum.
+func (i *Installer) publishedSHA256(version string, asset string) (string, error) {
+	url := fmt.Sprintf("%s/tags/%s", i.checksumURL, version)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("no published checksum for cloud-sql-proxy %s (%s: %s), pass the expected sha256", version, url, resp.Status)
+	}
+	var release struct {
+		Body string `json:"body"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&release)
+	if err != nil {
+		return "", fmt.Errorf("release notes of cloud-sql-proxy %s are not valid: %w", version, err)
+	}
+	// The asset must not be followed by more of a name, as cloud-sql-proxy.linux.arm is
+	// the start of cloud-sql-proxy.linux.arm64
+	name := regexp.MustCompile(`(^|[^\w.-])` + regexp.QuoteMeta(asset) + `($|[^\w.-])`)
+	for _, line := range strings.Split(release.Body, "\n") {
+		if !name.MatchString(line) {
+			continue
+		}
+		if sum := releaseNotesSHA256Regex.FindString(line); sum != "" {
+			return sum, nil
+		}
+	}
+	return "", fmt.Errorf("no published checksum for cloud-sql-proxy %s (%s), pass the expected sha256", version, asset)
+}
+
+// Versions returns the installed versions in ascending order.
+func (i *Installer) Versions() ([]string, error) {
+	entries, err := os.ReadDir(i.dirPath)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !versionRegex.MatchString(entry.Name()) {
+			continue
+		}
+		_, err := os.Stat(i.binaryPath(entry.Name()))
+		if err == nil {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sort.Slice(versions, func(a, b int) bool {
+		return compareVersions(versions[a], versions[b]) < 0
+	})
+	return versions, nil
+}
+
+// Current returns the version in use, or SystemVersion when the proxy on PATH is used.
+func (i *Installer) Current() (string, error) {
+	data, err := os.ReadFile(filepath.Join(i.dirPath, currentFile))
+	if os.IsNotExist(err) {
+		return SystemVersion, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Use makes an installed version current. SystemVersion switches back to the proxy on PATH.
+func (i *Installer) Use(version string) error {
+	if version == SystemVersion {
+		err := os.Remove(filepath.Join(i.dirPath, currentFile))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	version, err := NormalizeVersion(version)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(i.binaryPath(version))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cloud-sql-proxy %s is not installed", version)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(i.dirPath, currentFile), []byte(version+"\n"), 0600)
+}
+
+// Binary returns the path of the current version, or "" when the proxy on PATH is used.
+func (i *Installer) Binary() (string, error) {
+	version, err := i.Current()
+	if err != nil || version == SystemVersion {
+		return "", err
+	}
+	return i.binaryPath(version), nil
+}
+
+// compareVersions compares two versions matching versionRegex.
+func compareVersions(a string, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for n := range pa {
+		if len(pa[n]) != len(pb[n]) {
+			return len(pa[n]) - len(pb[n])
+		}
+		if c := strings.Compare(pa[n], pb[n]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,283 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var releaseBody = []byte("#!/bin/sh\necho cloud-sql-proxy\n")
+
+func releaseSHA256() string {
+	sum := sha256.Sum256(releaseBody)
+	return hex.EncodeToString(sum[:])
+}
+
+// releaseNotes returns the body of the release notes of the version, listing the sums of
+// the files like the cloud-sql-proxy releases do.
+func releaseNotes(version string, sums map[string]string) string {
+	notes := "## " + version + "\n\n### Bug Fixes\n\n* fix a bug\n\n| filename | sha256 hash |\n|----------|-------------|\n"
+	for _, asset := range []string{"cloud-sql-proxy.darwin.amd64", "cloud-sql-proxy.linux.amd64", "cloud-sql-proxy.linux.arm", "cloud-sql-proxy.linux.arm64", "cloud-sql-proxy.x64.exe"} {
+		if sum, ok := sums[asset]; ok {
+			notes += fmt.Sprintf("| [%s](https://storage.googleapis.com/cloud-sql-connectors/cloud-sql-proxy/%s/%s) | %s |\n", asset, version, asset, sum)
+		}
+	}
+	return notes
+}
+
+// serveReleaseNotes serves the notes like the GitHub releases API does.
+func serveReleaseNotes(w http.ResponseWriter, notes string) {
+	json.NewEncoder(w).Encode(map[string]string{"tag_name": "v2.14.0", "body": notes})
+}
+
+// newReleaseServer serves releaseBody for the given versions like the release bucket does,
+// and the release notes listing its SHA-256 sum when published is true.
+func newReleaseServer(t *testing.T, published bool, versions ...string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	for _, version := range versions {
+		mux.HandleFunc("/"+version+"/cloud-sql-proxy.linux.amd64", func(w http.ResponseWriter, r *http.Request) {
+			w.Write(releaseBody)
+		})
+		if published {
+			mux.HandleFunc("/tags/"+version, func(w http.ResponseWriter, r *http.Request) {
+				serveReleaseNotes(w, releaseNotes(version, map[string]string{
+					"cloud-sql-proxy.darwin.amd64": hex.EncodeToString(make([]byte, 32)),
+					"cloud-sql-proxy.linux.amd64":  releaseSHA256(),
+				}))
+			})
+		}
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestInstaller(t *testing.T, server *httptest.Server) *Installer {
+	return newTestInstallerWith(t, server, server)
+}
+
+func newTestInstallerWith(t *testing.T, download *httptest.Server, checksums *httptest.Server) *Installer {
+	i := NewInstaller(t.TempDir(), download.URL, checksums.URL)
+	i.goos = "linux"
+	i.goarch = "amd64"
+	return i
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{version: "v2.14.0", want: "v2.14.0"},
+		{version: "2.14.0", want: "v2.14.0"},
+		{version: "v2.14", wantErr: true},
+		{version: "latest", wantErr: true},
+		{version: "v2.14.0/../x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := NormalizeVersion(tt.version)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAssetName(t *testing.T) {
+	tests := []struct {
+		goos    string
+		goarch  string
+		want    string
+		wantErr bool
+	}{
+		{goos: "linux", goarch: "amd64", want: "cloud-sql-proxy.linux.amd64"},
+		{goos: "darwin", goarch: "arm64", want: "cloud-sql-proxy.darwin.arm64"},
+		{goos: "windows", goarch: "amd64", want: "cloud-sql-proxy.x64.exe"},
+		{goos: "darwin", goarch: "386", wantErr: true},
+		{goos: "plan9", goarch: "amd64", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goos+"/"+tt.goarch, func(t *testing.T) {
+			got, err := assetName(tt.goos, tt.goarch)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInstaller_Install(t *testing.T) {
+	server := newReleaseServer(t, true, "v2.14.0")
+	i := newTestInstaller(t, server)
+
+	path, err := i.Install("2.14.0", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(i.dirPath, "v2.14.0", "cloud-sql-proxy"), path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, releaseBody, data)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	current, err := i.Current()
+	require.NoError(t, err)
+	assert.Equal(t, "v2.14.0", current)
+	binary, err := i.Binary()
+	require.NoError(t, err)
+	assert.Equal(t, path, binary)
+}
+
+func TestInstaller_Install_SHA256(t *testing.T) {
+	server := newReleaseServer(t, false, "v2.14.0")
+
+	t.Run("matches", func(t *testing.T) {
+		_, err := newTestInstaller(t, server).Install("v2.14.0", releaseSHA256())
+		assert.NoError(t, err)
+	})
+	t.Run("does not match", func(t *testing.T) {
+		i := newTestInstaller(t, server)
+		_, err := i.Install("v2.14.0", hex.EncodeToString(make([]byte, 32)))
+		assert.EqualError(t, err, "checksum does not match, the download may be corrupted")
+		versions, err := i.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+	t.Run("no checksum", func(t *testing.T) {
+		i := newTestInstaller(t, server)
+		_, err := i.Install("v2.14.0", "")
+		assert.ErrorContains(t, err, "no published checksum for cloud-sql-proxy v2.14.0")
+		versions, err := i.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+	t.Run("not valid", func(t *testing.T) {
+		_, err := newTestInstaller(t, server).Install("v2.14.0", "abc")
+		assert.EqualError(t, err, "sha256 is not valid")
+	})
+}
+
+func TestInstaller_publishedSHA256(t *testing.T) {
+	arm := strings.Repeat("a", 64)
+	arm64 := strings.Repeat("b", 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/tags/v2.14.0", r.URL.Path)
+		assert.Equal(t, "application/vnd.github+json", r.Header.Get("Accept"))
+		serveReleaseNotes(w, releaseNotes("v2.14.0", map[string]string{
+			"cloud-sql-proxy.linux.arm64": arm64,
+			"cloud-sql-proxy.linux.arm":   arm,
+		}))
+	}))
+	defer server.Close()
+	i := newTestInstaller(t, server)
+
+	sum, err := i.publishedSHA256("v2.14.0", "cloud-sql-proxy.linux.arm")
+	require.NoError(t, err)
+	assert.Equal(t, arm, sum)
+	sum, err = i.publishedSHA256("v2.14.0", "cloud-sql-proxy.linux.arm64")
+	require.NoError(t, err)
+	assert.Equal(t, arm64, sum)
+
+	_, err = i.publishedSHA256("v2.14.0", "cloud-sql-proxy.linux.amd64")
+	assert.EqualError(t, err, "no published checksum for cloud-sql-proxy v2.14.0 (cloud-sql-proxy.linux.amd64), pass the expected sha256")
+}
+
+func TestNewInstaller_Defaults(t *testing.T) {
+	i := NewInstaller(t.TempDir(), "", "")
+
+	assert.Equal(t, DefaultDownloadURL, i.baseURL)
+	assert.Equal(t, DefaultReleasesURL, i.checksumURL)
+}
+
+func TestInstaller_Install_Mirror(t *testing.T) {
+	checksums := newReleaseServer(t, true, "v2.14.0")
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A mirror cannot vouch for the binaries it serves
+		if r.URL.Path == "/tags/v2.14.0" {
+			sum := sha256.Sum256([]byte("tampered"))
+			serveReleaseNotes(w, releaseNotes("v2.14.0", map[string]string{"cloud-sql-proxy.linux.amd64": hex.EncodeToString(sum[:])}))
+			return
+		}
+		w.Write([]byte("tampered"))
+	}))
+	defer mirror.Close()
+
+	t.Run("tampered", func(t *testing.T) {
+		_, err := newTestInstallerWith(t, mirror, checksums).Install("v2.14.0", "")
+		assert.EqualError(t, err, "checksum does not match, the download may be corrupted")
+	})
+	t.Run("intact", func(t *testing.T) {
+		intact := newReleaseServer(t, false, "v2.14.0")
+		_, err := newTestInstallerWith(t, intact, checksums).Install("v2.14.0", "")
+		assert.NoError(t, err)
+	})
+}
+
+func TestInstaller_Install_NotFound(t *testing.T) {
+	server := newReleaseServer(t, true)
+
+	_, err := newTestInstaller(t, server).Install("v9.9.9", "")
+
+	assert.EqualError(t, err, "cloud-sql-proxy v9.9.9 is not available for linux/amd64")
+}
+
+func TestInstaller_VersionsAndUse(t *testing.T) {
+	server := newReleaseServer(t, true, "v2.9.0", "v2.14.0")
+	i := newTestInstaller(t, server)
+
+	current, err := i.Current()
+	require.NoError(t, err)
+	assert.Equal(t, SystemVersion, current)
+	binary, err := i.Binary()
+	require.NoError(t, err)
+	assert.Empty(t, binary)
+
+	_, err = i.Install("v2.14.0", "")
+	require.NoError(t, err)
+	_, err = i.Install("v2.9.0", "")
+	require.NoError(t, err)
+
+	versions, err := i.Versions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v2.9.0", "v2.14.0"}, versions)
+
+	require.NoError(t, i.Use("v2.14.0"))
+	current, err = i.Current()
+	require.NoError(t, err)
+	assert.Equal(t, "v2.14.0", current)
+
+	assert.EqualError(t, i.Use("v2.1.0"), "cloud-sql-proxy v2.1.0 is not installed")
+
+	require.NoError(t, i.Use(SystemVersion))
+	current, err = i.Current()
+	require.NoError(t, err)
+	assert.Equal(t, SystemVersion, current)
+}
+
+func TestProxy_UseBinary(t *testing.T) {
+	p, _ := newTestProxy(t)
+
+	p.UseBinary("/opt/tsunagi/cloud-sql-proxy")
+
+	assert.Equal(t, "/opt/tsunagi/cloud-sql-proxy", p.binary)
+}
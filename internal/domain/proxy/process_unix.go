@@ -0,0 +1,26 @@
+//go:build !windows
+
+package proxy
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the proxy in its own session so it outlives the terminal that started it.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+func terminate(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,341 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/utils"
+)
+
+const proxyBinary = "cloud-sql-proxy"
+
+type Phase string
+
+const (
+	PhaseRunning    Phase = "running"
+	PhaseRestarting Phase = "restarting"
+	PhaseFailed     Phase = "failed"
+	PhaseStopped    Phase = "stopped" // stopped by tsunagi, for example for being idle
+	PhaseExited     Phase = "exited"
+)
+
+type State struct {
+	Name      string
+	PID       int
+	Port      int
+	StartedAt time.Time
+	// Supervisor is the pid of the tsunagi process restarting the proxy, if any.
+	Supervisor int       `json:",omitempty"`
+	Phase      Phase     `json:",omitempty"`
+	Restarts   int       `json:",omitempty"`
+	LastExit   string    `json:",omitempty"`
+	LastExitAt time.Time `json:",omitzero"`
+	// ExpiresAt is when a time-boxed session ends.
+	ExpiresAt time.Time `json:",omitzero"`
+	// ProxyPort is the port the proxy listens on when tsunagi forwards the config's port to it.
+	ProxyPort int `json:",omitempty"`
+	// Connections counts the client connections of a proxy tsunagi forwards the port of.
+	Connections *ConnectionStats `json:",omitempty"`
+}
+
+// Alive reports whether the proxy process of the state, or its supervisor, is still running.
+// A supervisor may outlive proxies it gave up on or stopped, as the daemon does.
+func (s State) Alive() bool {
+	if s.Phase == PhaseFailed || s.Phase == PhaseStopped {
+		return false
+	}
+	if s.Supervisor != 0 {
+		return processAlive(s.Supervisor)
+	}
+	return processAlive(s.PID)
+}
+
+// Status returns the phase of the proxy, taking into account whether it is still alive.
+func (s State) Status() Phase {
+	if !s.Alive() {
+		if s.Phase == PhaseFailed || s.Phase == PhaseStopped {
+			return s.Phase
+		}
+		return PhaseExited
+	}
+	if s.Phase == "" {
+		return PhaseRunning
+	}
+	return s.Phase
+}
+
+// StatusStopped is how a session stopped by the user ended.
+const StatusStopped = "stopped"
+
+type Proxy struct {
+	r            Repository
+	binary       string
+	command      func(name string, arg ...string) *exec.Cmd
+	preflight    func(param config.ConfigParam) error
+	notify       func(name string, message string)
+	onStop       func(state State, status string)
+	startTimeout time.Duration
+	stopTimeout  time.Duration
+}
+
+func NewProxy(r Repository) *Proxy {
+	return &Proxy{
+		r:            r,
+		binary:       proxyBinary,
+		command:      exec.Command,
+		preflight:    preflight,
+		notify:       func(name string, message string) {},
+		onStop:       func(state State, status string) {},
+		startTimeout: 30 * time.Second,
+		stopTimeout:  10 * time.Second,
+	}
+}
+
+// UseBinary makes the proxy run the cloud-sql-proxy at path instead of the one on PATH.
+func (p *Proxy) UseBinary(path string) {
+	p.binary = path
+}
+
+// SetNotifier sets the function warning the user, for example before a session expires.
+func (p *Proxy) SetNotifier(notify func(name string, message string)) {
+	p.notify = notify
+}
+
+// SetOnStop sets the function called after a proxy stopped, with its last state and how it
+// ended. Supervised proxies report it from the supervisor when they stop for good.
+func (p *Proxy) SetOnStop(onStop func(state State, status string)) {
+	p.onStop = onStop
+}
+
+// Args returns the cloud-sql-proxy arguments for the config.
+func Args(param config.ConfigParam) []string {
+	args := []string{"--port", strconv.Itoa(param.Port)}
+	if param.AutoIAMAuthn {
+		args = append(args, "--auto-iam-authn")
+	}
+	switch param.IPType {
+	case cloud.IPTypePrivate:
+		args = append(args, "--private-ip")
+	case cloud.IPTypePSC:
+		args = append(args, "--psc")
+	}
+	if param.ImpersonateServiceAccount != "" {
+		args = append(args, "--impersonate-service-account", param.ImpersonateServiceAccount)
+	}
+	if param.GcloudContext().UsesGcloudCredentials() {
+		args = append(args, "--gcloud-auth")
+	}
+	if param.HealthCheckPort != 0 {
+		args = append(args, "--health-check", "--http-address", "127.0.0.1", "--http-port", strconv.Itoa(param.HealthCheckPort))
+	}
+	return append(args, cloud.ConnectionName(param.ProjectName, param.Region, param.InstanceName))
+}
+
+// Version returns the output of cloud-sql-proxy --version.
+func (p *Proxy) Version() (string, error) {
+	out, err := p.command(p.binary, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Running returns the state of the running proxy for the config, or nil if it is not running.
+// A state left behind by a proxy that is no longer alive is removed.
+func (p *Proxy) Running(name string) (*State, error) {
+	state, err := p.r.Find(name)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	if !state.Alive() {
+		return nil, p.r.Delete(name)
+	}
+	return state, nil
+}
+
+// List returns the states of all proxies started by tsunagi, including ones that are no longer alive.
+func (p *Proxy) List() ([]State, error) {
+	states, err := p.r.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states, nil
+}
+
+// CheckStart checks that the proxy for the config is not running, that its port is free
+// and that it will be able to authenticate.
+func (p *Proxy) CheckStart(param config.ConfigParam) error {
+	running, err := p.Running(param.Name)
+	if err != nil {
+		return err
+	}
+	if running != nil {
+		return fmt.Errorf("proxy for %q is already running (pid %d)", param.Name, running.PID)
+	}
+	if !portAvailable(param.Port) {
+		return fmt.Errorf("port %d is already in use", param.Port)
+	}
+	return p.preflight(param)
+}
+
+// proxyCommand returns the cloud-sql-proxy command for the config.
+func (p *Proxy) proxyCommand(param config.ConfigParam) *exec.Cmd {
+	cmd := p.command(p.binary, Args(param)...)
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, param.GcloudContext().Env()...)
+	return cmd
+}
+
+// WithSession returns the config with a session of duration d, which cannot be longer than
+// the config's max session duration.
+func WithSession(param config.ConfigParam, d time.Duration) (config.ConfigParam, error) {
+	if d <= 0 {
+		return param, errors.New("session duration must be positive")
+	}
+	if d < config.MinSessionDuration {
+		return param, fmt.Errorf("session duration must be at least %s", config.MinSessionDuration)
+	}
+	if limit := param.MaxSession(); limit > 0 && d > limit {
+		return param, fmt.Errorf("sessions for %q cannot be longer than %s", param.Name, limit)
+	}
+	param.MaxSessionDuration = d.String()
+	return param, nil
+}
+
+// Extend moves the end of the running proxy's session d later. The remaining time cannot
+// exceed the config's max session duration. The new end is saved apart from the state, which
+// only the supervisor writes, and the supervisor picks it up on its next check.
+func (p *Proxy) Extend(param config.ConfigParam, d time.Duration) (*State, error) {
+	if d <= 0 {
+		return nil, errors.New("extension must be positive")
+	}
+	state, err := p.Running(param.Name)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, fmt.Errorf("proxy for %q is not running", param.Name)
+	}
+	if state.ExpiresAt.IsZero() {
+		return nil, fmt.Errorf("proxy for %q has no session time limit", param.Name)
+	}
+	stored, err := p.r.FindExpiry(param.Name)
+	if err != nil {
+		return nil, err
+	}
+	if stored.After(state.ExpiresAt) {
+		state.ExpiresAt = stored
+	}
+	expiresAt := state.ExpiresAt.Add(d)
+	if limit := param.MaxSession(); limit > 0 && time.Until(expiresAt) > limit {
+		return nil, fmt.Errorf("sessions for %q cannot be longer than %s", param.Name, limit)
+	}
+	state.ExpiresAt = expiresAt
+	err = p.r.SaveExpiry(param.Name, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (p *Proxy) Stop(name string) error {
+	state, err := p.Running(name)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("proxy for %q is not running", name)
+	}
+
+	pid := state.PID
+	timeout := p.stopTimeout
+	if state.Supervisor != 0 {
+		// The supervisor stops the proxy itself, which may take up to stopTimeout.
+		pid = state.Supervisor
+		timeout *= 2
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	err = terminate(process)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			process.Kill()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return p.r.Delete(name)
+}
+
+// preflight checks that the proxy will be able to authenticate before it is started.
+func preflight(param config.ConfigParam) error {
+	gc := param.GcloudContext()
+	err := utils.CheckGcloudAuth(gc)
+	if err != nil {
+		return err
+	}
+	if param.ImpersonateServiceAccount != "" {
+		return utils.CheckImpersonation(gc, param.ImpersonateServiceAccount)
+	}
+	return nil
+}
+
+func portAvailable(port int) bool {
+	l, err := net.Listen("tcp", localAddress(port))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+func waitForPort(ctx context.Context, port int, exited <-chan error, timeout time.Duration) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-exited:
+			if err == nil {
+				return errors.New("proxy exited before listening")
+			}
+			return fmt.Errorf("proxy exited before listening: %w", err)
+		case <-deadline:
+			return errors.New("timed out waiting for proxy to listen")
+		case <-ticker.C:
+			conn, err := net.DialTimeout("tcp", localAddress(port), time.Second)
+			if err == nil {
+				conn.Close()
+				return nil
+			}
+		}
+	}
+}
+
+func localAddress(port int) string {
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
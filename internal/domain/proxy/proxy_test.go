@@ -0,0 +1,311 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/cloud"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockRepository is an in-memory implementation of the Repository interface for testing
+type mockRepository struct {
+	mu       sync.Mutex
+	states   map[string]State
+	expiries map[string]time.Time
+}
+
+func newMockRepository() *mockRepository {
+	return &mockRepository{states: map[string]State{}}
+}
+
+func (m *mockRepository) Save(state State) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[state.Name] = state
+	return nil
+}
+
+func (m *mockRepository) Find(name string) (*State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.states[name]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (m *mockRepository) FindAll() ([]State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var states []State
+	for _, state := range m.states {
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (m *mockRepository) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, name)
+	delete(m.expiries, name)
+	return nil
+}
+
+func (m *mockRepository) SaveExpiry(name string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.expiries == nil {
+		m.expiries = map[string]time.Time{}
+	}
+	m.expiries[name] = expiresAt
+	return nil
+}
+
+func (m *mockRepository) FindExpiry(name string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.expiries[name], nil
+}
+
+// TestHelperProcess is not a real test. It acts as a fake cloud-sql-proxy
+// that listens on the port given by --port until it is terminated.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	if os.Args[len(os.Args)-1] == "--version" {
+		fmt.Println("cloud-sql-proxy version 2.14.0+linux.amd64")
+		os.Exit(0)
+	}
+	if os.Getenv("HELPER_EXIT") == "1" {
+		os.Exit(1)
+	}
+	if want := os.Getenv("HELPER_WANT_CONFIG"); want != "" && os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME") != want {
+		os.Exit(3)
+	}
+	var port string
+	for i, arg := range os.Args {
+		if arg == "--port" && i+1 < len(os.Args) {
+			port = os.Args[i+1]
+		}
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		os.Exit(2)
+	}
+	defer l.Close()
+	if after := os.Getenv("HELPER_CRASH_AFTER"); after != "" {
+		d, _ := time.ParseDuration(after)
+		time.Sleep(d)
+		fmt.Fprintln(os.Stderr, "connection to metadata server lost")
+		os.Exit(1)
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	<-sig
+	os.Exit(0)
+}
+
+func newTestProxy(t *testing.T, env ...string) (*Proxy, *mockRepository) {
+	t.Helper()
+	repo := newMockRepository()
+	p := NewProxy(repo)
+	p.startTimeout = 5 * time.Second
+	p.stopTimeout = 5 * time.Second
+	p.preflight = func(param config.ConfigParam) error { return nil }
+	p.command = func(name string, arg ...string) *exec.Cmd {
+		cmd := exec.Command(os.Args[0], append([]string{"-test.run=TestHelperProcess", "--", name}, arg...)...)
+		cmd.Env = append(os.Environ(), append(env, "GO_WANT_HELPER_PROCESS=1")...)
+		return cmd
+	}
+	return p, repo
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// deadPID returns the pid of a process that has already exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+func testParam(t *testing.T) config.ConfigParam {
+	return config.ConfigParam{
+		Name:         "test-config",
+		Port:         freePort(t),
+		ProjectName:  "test-project",
+		Region:       "asia-northeast1",
+		InstanceName: "test-instance",
+	}
+}
+
+func TestArgs(t *testing.T) {
+	param := config.ConfigParam{
+		Name:         "test-config",
+		Port:         50000,
+		ProjectName:  "test-project",
+		Region:       "asia-northeast1",
+		InstanceName: "test-instance",
+	}
+
+	assert.Equal(t, []string{"--port", "50000", "test-project:asia-northeast1:test-instance"}, Args(param))
+
+	param.AutoIAMAuthn = true
+	assert.Equal(t, []string{"--port", "50000", "--auto-iam-authn", "test-project:asia-northeast1:test-instance"}, Args(param))
+
+	param.AutoIAMAuthn = false
+	param.IPType = cloud.IPTypePrivate
+	assert.Equal(t, []string{"--port", "50000", "--private-ip", "test-project:asia-northeast1:test-instance"}, Args(param))
+
+	param.IPType = cloud.IPTypePSC
+	assert.Equal(t, []string{"--port", "50000", "--psc", "test-project:asia-northeast1:test-instance"}, Args(param))
+
+	param.IPType = ""
+	param.ImpersonateServiceAccount = "delegate@p.iam.gserviceaccount.com,reader@p.iam.gserviceaccount.com"
+	assert.Equal(t, []string{
+		"--port", "50000",
+		"--impersonate-service-account", "delegate@p.iam.gserviceaccount.com,reader@p.iam.gserviceaccount.com",
+		"test-project:asia-northeast1:test-instance",
+	}, Args(param))
+
+	param.ImpersonateServiceAccount = ""
+	param.GcloudConfiguration = "client-a"
+	assert.Equal(t, []string{"--port", "50000", "--gcloud-auth", "test-project:asia-northeast1:test-instance"}, Args(param))
+
+	param.CredentialsFile = "/keys/client-a.json"
+	assert.Equal(t, []string{"--port", "50000", "test-project:asia-northeast1:test-instance"}, Args(param))
+
+	param.HealthCheckPort = 9090
+	assert.Equal(t, []string{"--port", "50000", "--health-check", "--http-address", "127.0.0.1", "--http-port", "9090", "test-project:asia-northeast1:test-instance"}, Args(param))
+}
+
+func TestProxy_Version(t *testing.T) {
+	p, _ := newTestProxy(t)
+
+	version, err := p.Version()
+	require.NoError(t, err)
+	assert.Equal(t, "cloud-sql-proxy version 2.14.0+linux.amd64", version)
+}
+
+func TestState_Alive(t *testing.T) {
+	assert.True(t, State{PID: os.Getpid()}.Alive())
+	assert.False(t, State{PID: deadPID(t)}.Alive())
+	assert.True(t, State{PID: deadPID(t), Supervisor: os.Getpid()}.Alive())
+}
+
+func TestProxy_CheckStart(t *testing.T) {
+	p, repo := newTestProxy(t)
+	param := testParam(t)
+
+	require.NoError(t, p.CheckStart(param))
+
+	repo.Save(State{Name: param.Name, PID: os.Getpid(), Port: param.Port})
+	err := p.CheckStart(param)
+	assert.EqualError(t, err, `proxy for "test-config" is already running (pid `+strconv.Itoa(os.Getpid())+`)`)
+}
+
+func TestProxy_CheckStart_PreflightError(t *testing.T) {
+	p, _ := newTestProxy(t)
+	p.preflight = func(param config.ConfigParam) error {
+		return errors.New("cannot impersonate reader@p.iam.gserviceaccount.com")
+	}
+
+	err := p.CheckStart(testParam(t))
+	assert.EqualError(t, err, "cannot impersonate reader@p.iam.gserviceaccount.com")
+}
+
+func TestProxy_CheckStart_PortInUse(t *testing.T) {
+	p, _ := newTestProxy(t)
+	param := testParam(t)
+
+	l, err := net.Listen("tcp", localAddress(param.Port))
+	require.NoError(t, err)
+	defer l.Close()
+
+	err = p.CheckStart(param)
+	assert.EqualError(t, err, "port "+strconv.Itoa(param.Port)+" is already in use")
+}
+
+func TestProxy_Stop(t *testing.T) {
+	p, repo := newTestProxy(t)
+	var stopped []string
+	p.SetOnStop(func(state State, status string) {
+		stopped = append(stopped, state.Name+": "+status)
+	})
+	supervisor := exec.Command("sleep", "30")
+	require.NoError(t, supervisor.Start())
+	exited := make(chan error, 1)
+	go func() {
+		exited <- supervisor.Wait()
+	}()
+	repo.Save(State{Name: "test-config", PID: deadPID(t), Supervisor: supervisor.Process.Pid})
+
+	err := p.Stop("test-config")
+	require.NoError(t, err)
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor was not stopped")
+	}
+	assert.NotContains(t, repo.states, "test-config")
+	// The supervisor reports how the session ended
+	assert.Empty(t, stopped)
+
+	err = p.Stop("test-config")
+	assert.EqualError(t, err, `proxy for "test-config" is not running`)
+}
+
+func TestProxy_Supervise_GcloudContext(t *testing.T) {
+	p, repo := newTestProxy(t, "HELPER_WANT_CONFIG=client-a")
+	param := testParam(t)
+	param.GcloudConfiguration = "client-a"
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Supervise(ctx, param, testPolicy(), nil)
+	}()
+
+	require.Eventually(t, func() bool {
+		state, _ := repo.Find("test-config")
+		return state != nil && state.Phase == PhaseRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestProxy_Running_StaleState(t *testing.T) {
+	p, repo := newTestProxy(t)
+
+	// start and reap a short-lived process to get a pid that is no longer alive
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	repo.states["stale"] = State{Name: "stale", PID: cmd.Process.Pid}
+
+	state, err := p.Running("stale")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+	assert.NotContains(t, repo.states, "stale")
+}
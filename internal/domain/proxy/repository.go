@@ -0,0 +1,16 @@
+package proxy
+
+import "time"
+
+type Repository interface {
+	Save(state State) error
+	Find(name string) (*State, error)
+	FindAll() ([]State, error)
+	// Delete deletes the state and the session expiry of the proxy.
+	Delete(name string) error
+	// SaveExpiry saves when the session of the proxy ends, apart from its state so that only
+	// the supervisor writes the state. A zero time removes it.
+	SaveExpiry(name string, expiresAt time.Time) error
+	// FindExpiry returns when the session of the proxy ends, or the zero time if it was not saved.
+	FindExpiry(name string) (time.Time, error)
+}
@@ -0,0 +1,435 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+)
+
+// minCheckInterval is the shortest interval at which the supervisor checks the limits of a proxy.
+const minCheckInterval = 10 * time.Millisecond
+
+// RestartPolicy controls how a supervised proxy is restarted after it exits.
+type RestartPolicy struct {
+	// MaxRetries is the number of consecutive restarts before giving up.
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// StableAfter is how long the proxy has to run for the retry count to be reset.
+	StableAfter time.Duration
+}
+
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		StableAfter:    time.Minute,
+	}
+}
+
+// backoff returns the delay before the nth consecutive restart, starting at 1.
+func (r RestartPolicy) backoff(n int) time.Duration {
+	delay := r.InitialBackoff
+	for i := 1; i < n && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.MaxBackoff)
+}
+
+// StartSupervised starts the supervisor command, a detached tsunagi process that runs
+// the proxy through Supervise, and waits until the proxy listens.
+func (p *Proxy) StartSupervised(param config.ConfigParam, supervisor *exec.Cmd) (*State, error) {
+	err := p.CheckStart(param)
+	if err != nil {
+		return nil, err
+	}
+
+	detach(supervisor)
+	err = supervisor.Start()
+	if err != nil {
+		return nil, err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- supervisor.Wait()
+	}()
+
+	// The supervisor saves the state once it sees the proxy listening. Dialing the port would
+	// not tell, as a forwarder in front of the proxy listens before it, and count as a client.
+	err = p.waitForState(param.Name, supervisor.Process.Pid, exited)
+	if err != nil {
+		terminate(supervisor.Process)
+		return nil, err
+	}
+	return p.r.Find(param.Name)
+}
+
+func (p *Proxy) waitForState(name string, supervisor int, exited <-chan error) error {
+	deadline := time.Now().Add(p.startTimeout)
+	for time.Now().Before(deadline) {
+		state, err := p.r.Find(name)
+		if err != nil {
+			return err
+		}
+		if state != nil && state.Supervisor == supervisor && state.PID != 0 {
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("supervisor exited: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return errors.New("timed out waiting for supervisor")
+}
+
+// Supervise runs the proxy in the current process and restarts it with exponential backoff
+// when it exits, until ctx is done or the policy gives up. The state records the restarts and
+// the reason of the last exit. When the proxy cannot be started in the first place, Supervise
+// returns the error without retrying.
+//
+// With an idle timeout the proxy is stopped once no client has been connected for that long,
+// and with a max session duration once its session expires, after a warning. The state of a
+// proxy stopped this way is kept to tell why. Clients connect to the proxy through a forwarder,
+// whose connection counts are saved in the state as they change. Once the proxy stops for good, the
+// function set with SetOnStop is called. The proxy's output and tsunagi's messages are
+// written to output when it is not nil.
+func (p *Proxy) Supervise(ctx context.Context, param config.ConfigParam, policy RestartPolicy, output io.Writer) error {
+	if output == nil {
+		output = io.Discard
+	}
+	// The proxy's stdout and stderr are copied by separate goroutines
+	output = &syncWriter{w: output}
+	state := State{
+		Name:       param.Name,
+		Port:       param.Port,
+		StartedAt:  time.Now(),
+		Supervisor: os.Getpid(),
+	}
+	lim := &limits{p: p, name: param.Name, idleTimeout: param.IdleTimeoutDuration(), output: output}
+	interval := time.Second
+	if session := param.MaxSession(); session > 0 {
+		state.ExpiresAt = state.StartedAt.Add(session)
+		lim.warnBefore = min(5*time.Minute, session/5)
+		interval = min(interval, session/10)
+	}
+	// Extend saves a later expiry apart from the state, which only the supervisor writes
+	err := p.r.SaveExpiry(param.Name, state.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	// The proxy listens on an internal port behind a forwarder that counts the client connections.
+	target := param
+	port, err := freeLocalPort()
+	if err != nil {
+		return err
+	}
+	target.Port = port
+	lim.forwarder, err = Forward(localAddress(param.Port), localAddress(target.Port))
+	if err != nil {
+		return err
+	}
+	defer lim.forwarder.Close()
+	if lim.idleTimeout > 0 {
+		interval = min(interval, lim.idleTimeout/4)
+	}
+	state.ProxyPort = target.Port
+	state.Connections = &ConnectionStats{}
+	ticker := time.NewTicker(max(interval, minCheckInterval))
+	defer ticker.Stop()
+	tick := ticker.C
+
+	failures := 0
+	for {
+		cmd := p.proxyCommand(target)
+		stderr := &lastLineWriter{}
+		cmd.Stdout = output
+		cmd.Stderr = io.MultiWriter(stderr, output)
+		err := cmd.Start()
+		if err != nil {
+			return err
+		}
+		exited := make(chan error, 1)
+		go func() {
+			exited <- cmd.Wait()
+		}()
+
+		err = waitForPort(ctx, target.Port, exited, p.startTimeout)
+		if ctx.Err() != nil {
+			p.stopChild(cmd, exited)
+			return p.stopped(state)
+		}
+		if err != nil && state.PID == 0 {
+			cmd.Process.Kill()
+			return withReason(err, stderr)
+		}
+		if err == nil {
+			state.PID = cmd.Process.Pid
+			state.Phase = PhaseRunning
+			err = p.saveState(&state)
+			if err != nil {
+				p.stopChild(cmd, exited)
+				return err
+			}
+			listening := time.Now()
+		wait:
+			for {
+				select {
+				case <-ctx.Done():
+					p.stopChild(cmd, exited)
+					return p.stopped(state)
+				case err = <-exited:
+					break wait
+				case <-tick:
+					if reason := lim.check(&state); reason != "" {
+						p.stopChild(cmd, exited)
+						return lim.stop(&state, reason)
+					}
+					err = p.saveChecked(&state, lim)
+					if err != nil {
+						p.stopChild(cmd, exited)
+						return err
+					}
+				}
+			}
+			if time.Since(listening) >= policy.StableAfter {
+				failures = 0
+			}
+			err = exitError(err)
+		} else {
+			cmd.Process.Kill()
+		}
+
+		failures++
+		state.LastExit = withReason(err, stderr).Error()
+		state.LastExitAt = time.Now()
+		if failures > policy.MaxRetries {
+			state.Phase = PhaseFailed
+			err = p.saveState(&state)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(output, "tsunagi: proxy exited (%s), giving up after %d restarts\n", state.LastExit, state.Restarts)
+			p.onStop(state, "failed: "+state.LastExit)
+			return fmt.Errorf("proxy for %q exited %d times in a row, giving up: %s", param.Name, failures, state.LastExit)
+		}
+		state.Phase = PhaseRestarting
+		err = p.saveState(&state)
+		if err != nil {
+			return err
+		}
+
+		delay := policy.backoff(failures)
+		fmt.Fprintf(output, "tsunagi: proxy exited (%s), restarting in %s\n", state.LastExit, delay)
+		backoff := time.After(delay)
+	sleep:
+		for {
+			select {
+			case <-ctx.Done():
+				return p.stopped(state)
+			case <-backoff:
+				break sleep
+			case <-tick:
+				if reason := lim.check(&state); reason != "" {
+					return lim.stop(&state, reason)
+				}
+				err = p.saveChecked(&state, lim)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		state.Restarts++
+	}
+}
+
+// stopped deletes the state of a proxy stopped through ctx.
+func (p *Proxy) stopped(state State) error {
+	err := p.r.Delete(state.Name)
+	if err != nil {
+		return err
+	}
+	if state.PID != 0 {
+		p.onStop(state, StatusStopped)
+	}
+	return nil
+}
+
+// saveChecked saves the state when the session was extended or the forwarder's connection
+// counts changed since the last check.
+func (p *Proxy) saveChecked(state *State, lim *limits) error {
+	changed := lim.extended
+	lim.extended = false
+	stats := lim.forwarder.Stats()
+	if state.Connections == nil || *state.Connections != stats {
+		state.Connections = &stats
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return p.saveState(state)
+}
+
+// saveState saves the supervisor's state, with the session expiry extended by Extend.
+func (p *Proxy) saveState(state *State) error {
+	p.refreshExpiry(state)
+	return p.r.Save(*state)
+}
+
+// refreshExpiry reports whether the session was extended.
+func (p *Proxy) refreshExpiry(state *State) bool {
+	expiresAt, err := p.r.FindExpiry(state.Name)
+	if err != nil || !expiresAt.After(state.ExpiresAt) {
+		return false
+	}
+	state.ExpiresAt = expiresAt
+	return true
+}
+
+// limits decides when a supervised proxy has to be stopped for being idle or for its session expiring.
+type limits struct {
+	p           *Proxy
+	name        string
+	forwarder   *Forwarder
+	idleTimeout time.Duration
+	warnBefore  time.Duration
+	warned      bool
+	extended    bool // since the state was last saved
+	output      io.Writer
+}
+
+// check returns why the proxy has to be stopped, or "" to keep it running.
+// It warns once when the session is about to expire, again after each extension.
+func (l *limits) check(state *State) string {
+	if l.idleTimeout > 0 && l.forwarder.IdleFor() >= l.idleTimeout {
+		return fmt.Sprintf("stopped after being idle for %s", l.idleTimeout)
+	}
+	if state.ExpiresAt.IsZero() {
+		return ""
+	}
+	if l.p.refreshExpiry(state) {
+		l.extended = true
+	}
+	remaining := time.Until(state.ExpiresAt)
+	if remaining <= 0 {
+		return fmt.Sprintf("session expired after %s", time.Since(state.StartedAt).Round(time.Second))
+	}
+	if remaining > l.warnBefore {
+		l.warned = false
+	} else if !l.warned {
+		l.warned = true
+		message := fmt.Sprintf("proxy for %q will be stopped in %s, run `tsunagi extend %s <duration>` to keep it", l.name, remaining.Round(time.Second), l.name)
+		fmt.Fprintf(l.output, "tsunagi: %s\n", message)
+		l.p.notify(l.name, message)
+	}
+	return ""
+}
+
+// stop records why the proxy was stopped.
+func (l *limits) stop(state *State, reason string) error {
+	state.Phase = PhaseStopped
+	state.LastExit = reason
+	state.LastExitAt = time.Now()
+	fmt.Fprintf(l.output, "tsunagi: stopping proxy (%s)\n", reason)
+	err := l.p.r.Save(*state)
+	if err != nil {
+		return err
+	}
+	l.p.onStop(*state, reason)
+	return nil
+}
+
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", localAddress(0))
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// stopChild terminates the proxy started by the supervisor and waits for it to exit.
+func (p *Proxy) stopChild(cmd *exec.Cmd, exited <-chan error) {
+	terminate(cmd.Process)
+	select {
+	case <-exited:
+	case <-time.After(p.stopTimeout):
+		cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// exitError describes how a proxy that was listening exited.
+func exitError(err error) error {
+	if err == nil {
+		return errors.New("exited with status 0")
+	}
+	return err
+}
+
+// withReason adds the last line the proxy wrote to stderr to err.
+func withReason(err error, stderr *lastLineWriter) error {
+	if line := stderr.String(); line != "" {
+		return fmt.Errorf("%w: %s", err, line)
+	}
+	return err
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(b)
+}
+
+// lastLineWriter keeps the last non-empty line written to it.
+type lastLineWriter struct {
+	mu      sync.Mutex
+	partial []byte
+	last    string
+}
+
+func (w *lastLineWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, b...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(w.partial[:i])); line != "" {
+			w.last = line
+		}
+		w.partial = w.partial[i+1:]
+	}
+	if len(w.partial) > 4096 {
+		w.partial = w.partial[len(w.partial)-4096:]
+	}
+	return len(b), nil
+}
+
+func (w *lastLineWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if line := strings.TrimSpace(string(w.partial)); line != "" {
+		return line
+	}
+	return w.last
+}
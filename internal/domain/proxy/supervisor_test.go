@@ -0,0 +1,342 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPolicy() RestartPolicy {
+	return RestartPolicy{
+		MaxRetries:     2,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		StableAfter:    time.Minute,
+	}
+}
+
+func TestRestartPolicy_backoff(t *testing.T) {
+	policy := RestartPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 8*time.Second, policy.backoff(4))
+	assert.Equal(t, 10*time.Second, policy.backoff(5))
+	assert.Equal(t, 10*time.Second, policy.backoff(50))
+}
+
+func TestProxy_Supervise_GivesUp(t *testing.T) {
+	p, repo := newTestProxy(t, "HELPER_CRASH_AFTER=100ms")
+	var stopped []string
+	p.SetOnStop(func(state State, status string) {
+		stopped = append(stopped, state.Name+": "+status)
+	})
+	param := testParam(t)
+	var output bytes.Buffer
+
+	err := p.Supervise(context.Background(), param, testPolicy(), &output)
+
+	assert.EqualError(t, err, `proxy for "test-config" exited 3 times in a row, giving up: exit status 1: connection to metadata server lost`)
+	state := repo.states["test-config"]
+	assert.Equal(t, PhaseFailed, state.Phase)
+	assert.Equal(t, 2, state.Restarts)
+	assert.Equal(t, "exit status 1: connection to metadata server lost", state.LastExit)
+	assert.False(t, state.LastExitAt.IsZero())
+	assert.Contains(t, output.String(), "connection to metadata server lost\n")
+	assert.Contains(t, output.String(), "tsunagi: proxy exited (exit status 1: connection to metadata server lost), restarting in 10ms\n")
+	assert.Contains(t, output.String(), "giving up after 2 restarts\n")
+	assert.Equal(t, []string{"test-config: failed: exit status 1: connection to metadata server lost"}, stopped)
+}
+
+func TestProxy_Supervise_Restarts(t *testing.T) {
+	p, repo := newTestProxy(t, "HELPER_CRASH_AFTER=300ms")
+	var stopped []State
+	p.SetOnStop(func(state State, status string) {
+		assert.Equal(t, StatusStopped, status)
+		stopped = append(stopped, state)
+	})
+	param := testParam(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Supervise(ctx, param, testPolicy(), nil)
+	}()
+
+	require.Eventually(t, func() bool {
+		state, _ := repo.Find("test-config")
+		return state != nil && state.Restarts == 1 && state.Phase == PhaseRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	state, _ := repo.Find("test-config")
+	assert.Equal(t, PhaseRunning, state.Status())
+	assert.Equal(t, "exit status 1: connection to metadata server lost", state.LastExit)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Empty(t, repo.states)
+	assert.True(t, portAvailable(param.Port))
+	require.Len(t, stopped, 1)
+	assert.Equal(t, 1, stopped[0].Restarts)
+}
+
+func TestProxy_Supervise_StartFailure(t *testing.T) {
+	p, repo := newTestProxy(t, "HELPER_EXIT=1")
+
+	err := p.Supervise(context.Background(), testParam(t), testPolicy(), nil)
+
+	assert.ErrorContains(t, err, "proxy exited before listening")
+	assert.Empty(t, repo.states)
+}
+
+func TestState_Status(t *testing.T) {
+	assert.Equal(t, PhaseRunning, State{PID: os.Getpid()}.Status())
+	assert.Equal(t, PhaseRestarting, State{Supervisor: os.Getpid(), Phase: PhaseRestarting}.Status())
+	assert.Equal(t, PhaseFailed, State{Supervisor: deadPID(t), Phase: PhaseFailed}.Status())
+	assert.Equal(t, PhaseExited, State{Supervisor: deadPID(t), Phase: PhaseRunning}.Status())
+	assert.Equal(t, PhaseExited, State{PID: deadPID(t)}.Status())
+}
+
+func TestLastLineWriter(t *testing.T) {
+	w := &lastLineWriter{}
+	w.Write([]byte("first\nsec"))
+	w.Write([]byte("ond\n\n"))
+	assert.Equal(t, "second", w.String())
+	w.Write([]byte("partial"))
+	assert.Equal(t, "partial", w.String())
+}
+
+func TestProxy_List(t *testing.T) {
+	p, repo := newTestProxy(t)
+	repo.Save(State{Name: "orders", PID: deadPID(t)})
+	repo.Save(State{Name: "billing", PID: os.Getpid()})
+
+	states, err := p.List()
+
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	assert.Equal(t, "billing", states[0].Name)
+	assert.Equal(t, "orders", states[1].Name)
+	// Unlike Running, List keeps the state of exited proxies so their status can be reported
+	assert.Len(t, repo.states, 2)
+}
+
+func TestProxy_Supervise_IdleTimeout(t *testing.T) {
+	p, repo := newTestProxy(t)
+	param := testParam(t)
+	param.IdleTimeout = "300ms"
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Supervise(context.Background(), param, testPolicy(), nil)
+	}()
+
+	require.Eventually(t, func() bool {
+		state, _ := repo.Find("test-config")
+		return state != nil && state.Phase == PhaseRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	// Clients connect to the forwarder on the config's port
+	conn, err := net.Dial("tcp", localAddress(param.Port))
+	require.NoError(t, err)
+	state, _ := repo.Find("test-config")
+	assert.Equal(t, param.Port, state.Port)
+	assert.NotZero(t, state.ProxyPort)
+	assert.NotEqual(t, param.Port, state.ProxyPort)
+
+	// An open connection keeps the proxy running
+	time.Sleep(600 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("proxy stopped while a client was connected: %v", err)
+	default:
+	}
+	// The connection counts are saved in the state
+	state, _ = repo.Find("test-config")
+	require.NotNil(t, state.Connections)
+	assert.Equal(t, 1, state.Connections.Active)
+	assert.Equal(t, int64(1), state.Connections.Total)
+
+	conn.Close()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy was not stopped for being idle")
+	}
+	state, _ = repo.Find("test-config")
+	require.NotNil(t, state)
+	assert.Equal(t, PhaseStopped, state.Status())
+	assert.Equal(t, "stopped after being idle for 300ms", state.LastExit)
+	assert.True(t, portAvailable(param.Port))
+	assert.False(t, processAlive(state.PID))
+}
+
+func TestProxy_Supervise_Connections(t *testing.T) {
+	p, repo := newTestProxy(t)
+	param := testParam(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Supervise(ctx, param, testPolicy(), nil)
+	}()
+	require.Eventually(t, func() bool {
+		state, _ := repo.Find("test-config")
+		return state != nil && state.Phase == PhaseRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Connections are counted without an idle timeout too
+	conn, err := net.Dial("tcp", localAddress(param.Port))
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool {
+		state, _ := repo.Find("test-config")
+		return state.Connections != nil && state.Connections.Active == 1 && state.Connections.Total == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestProxy_Supervise_TinyIdleTimeout(t *testing.T) {
+	p, repo := newTestProxy(t)
+	param := testParam(t)
+	// Shorter than configs can set, but the limits are still checked at a positive interval
+	param.IdleTimeout = "3ns"
+
+	err := p.Supervise(context.Background(), param, testPolicy(), nil)
+
+	require.NoError(t, err)
+	state, _ := repo.Find("test-config")
+	require.NotNil(t, state)
+	assert.Equal(t, PhaseStopped, state.Status())
+}
+
+func TestProxy_Supervise_SessionExpires(t *testing.T) {
+	p, repo := newTestProxy(t)
+	var notified []string
+	p.SetNotifier(func(name string, message string) {
+		notified = append(notified, name+": "+message)
+	})
+	param := testParam(t)
+	param.MaxSessionDuration = "1s"
+	var output bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Supervise(context.Background(), param, testPolicy(), &output)
+	}()
+
+	require.Eventually(t, func() bool {
+		state, _ := repo.Find("test-config")
+		return state != nil && state.Phase == PhaseRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	state, _ := repo.Find("test-config")
+	assert.WithinDuration(t, state.StartedAt.Add(time.Second), state.ExpiresAt, time.Millisecond)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy was not stopped when the session expired")
+	}
+	state, _ = repo.Find("test-config")
+	assert.Equal(t, PhaseStopped, state.Status())
+	assert.Contains(t, state.LastExit, "session expired after 1s")
+	assert.True(t, portAvailable(param.Port))
+	require.Len(t, notified, 1)
+	assert.Contains(t, notified[0], `test-config: proxy for "test-config" will be stopped in`)
+	assert.Contains(t, output.String(), "tsunagi: stopping proxy (session expired after 1s)")
+}
+
+func TestProxy_Extend(t *testing.T) {
+	p, repo := newTestProxy(t)
+	param := testParam(t)
+	param.MaxSessionDuration = "1s"
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Supervise(ctx, param, testPolicy(), nil)
+	}()
+	require.Eventually(t, func() bool {
+		state, _ := repo.Find("test-config")
+		return state != nil && state.Phase == PhaseRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Without a max session duration in the config, any extension is allowed
+	unlimited := param
+	unlimited.MaxSessionDuration = ""
+	state, err := p.Extend(unlimited, 2*time.Second)
+	require.NoError(t, err)
+	assert.WithinDuration(t, state.StartedAt.Add(3*time.Second), state.ExpiresAt, time.Millisecond)
+	// The supervisor saves the extended end in the state
+	require.Eventually(t, func() bool {
+		saved, _ := repo.Find("test-config")
+		return saved.ExpiresAt.Equal(state.ExpiresAt)
+	}, time.Second, 10*time.Millisecond)
+
+	time.Sleep(1500 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("proxy was stopped before the extended session ended")
+	default:
+	}
+
+	_, err = p.Extend(param, time.Hour)
+	assert.EqualError(t, err, `sessions for "test-config" cannot be longer than 1s`)
+
+	cancel()
+	require.NoError(t, <-done)
+	_, err = p.Extend(param, time.Minute)
+	assert.EqualError(t, err, `proxy for "test-config" is not running`)
+}
+
+func TestProxy_Extend_SupervisorOwnsState(t *testing.T) {
+	p, repo := newTestProxy(t)
+	expiresAt := time.Now().Add(time.Minute)
+	repo.Save(State{Name: "test-config", PID: os.Getpid(), ExpiresAt: expiresAt})
+
+	state, err := p.Extend(testParam(t), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, expiresAt.Add(time.Minute), state.ExpiresAt)
+
+	// Extend leaves the state to the supervisor, which may have saved a restart meanwhile
+	assert.Equal(t, expiresAt, repo.states["test-config"].ExpiresAt)
+	restarted := State{Name: "test-config", PID: os.Getpid(), Restarts: 1, ExpiresAt: expiresAt}
+	require.NoError(t, p.saveState(&restarted))
+	assert.Equal(t, 1, repo.states["test-config"].Restarts)
+	assert.Equal(t, expiresAt.Add(time.Minute), repo.states["test-config"].ExpiresAt)
+
+	// A second extension adds to the first one
+	state, err = p.Extend(testParam(t), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, expiresAt.Add(2*time.Minute), state.ExpiresAt)
+}
+
+func TestProxy_Extend_NoTimeLimit(t *testing.T) {
+	p, repo := newTestProxy(t)
+	repo.Save(State{Name: "test-config", PID: os.Getpid()})
+
+	_, err := p.Extend(testParam(t), time.Minute)
+
+	assert.EqualError(t, err, `proxy for "test-config" has no session time limit`)
+}
+
+func TestWithSession(t *testing.T) {
+	param := testParam(t)
+
+	got, err := WithSession(param, 30*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, got.MaxSession())
+
+	param.MaxSessionDuration = "1h"
+	_, err = WithSession(param, 2*time.Hour)
+	assert.EqualError(t, err, `sessions for "test-config" cannot be longer than 1h0m0s`)
+
+	_, err = WithSession(param, 5*time.Nanosecond)
+	assert.EqualError(t, err, "session duration must be at least 1s")
+
+	_, err = WithSession(param, 0)
+	assert.EqualError(t, err, "session duration must be positive")
+}
@@ -0,0 +1,26 @@
+package secret
+
+import "errors"
+
+type Backend string
+
+const (
+	BackendFile          Backend = "file"
+	BackendSecretService Backend = "secret-service"
+)
+
+var ErrNotFound = errors.New("secret not found")
+
+// Store keeps secrets such as database passwords outside the config file.
+type Store interface {
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	Delete(key string) error
+}
+
+func GetBackendList() []Backend {
+	return []Backend{
+		BackendFile,
+		BackendSecretService,
+	}
+}
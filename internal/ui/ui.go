@@ -0,0 +1,602 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+)
+
+// refreshInterval is how often the status of the proxies is refreshed.
+const refreshInterval = 2 * time.Second
+
+// logLines is the number of lines kept in the logs pane.
+const logLines = 500
+
+// Service is what the dashboard does with the configs and their proxies. The CLI implements
+// it with the same functions as its commands, so that both behave the same.
+type Service interface {
+	Configs() ([]config.ConfigParam, error)
+	States() ([]proxy.State, error)
+	// Start starts the proxy for the config, recording reason in the audit log.
+	Start(param config.ConfigParam, reason string) error
+	Stop(name string) error
+	// DSN returns the connection string of the config, without the password.
+	DSN(name string) (string, error)
+	// Logs calls f with the lines of the proxy's log and then with new ones until ctx is done.
+	Logs(ctx context.Context, name string, f func(line string)) error
+}
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	headerStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("8"))
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	prodStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	helpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	statusStyles  = map[string]lipgloss.Style{
+		string(proxy.PhaseRunning):    lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+		string(proxy.PhaseRestarting): lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		string(proxy.PhaseFailed):     lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
+	}
+)
+
+type refreshMsg struct {
+	configs []config.ConfigParam
+	states  []proxy.State
+	err     error
+	once    bool // refreshed after an action, outside the refresh loop
+}
+
+type tickMsg struct{}
+
+type actionMsg struct {
+	name   string
+	action string
+	err    error
+}
+
+type logMsg struct {
+	name  string
+	event logEvent
+	ok    bool
+}
+
+type logEvent struct {
+	line string
+	err  error
+}
+
+type copiedMsg struct {
+	name string
+	err  error
+}
+
+type promptKind int
+
+const (
+	promptConfirm promptKind = iota
+	promptReason
+)
+
+// prompt asks for the confirmation or the reason required to start a proxy.
+type prompt struct {
+	kind    promptKind
+	param   config.ConfigParam
+	restart bool
+	reason  string
+	input   textinput.Model
+}
+
+// logPane follows the log of a proxy.
+type logPane struct {
+	name   string
+	lines  []string
+	events chan logEvent
+	cancel context.CancelFunc
+}
+
+// Model is the dashboard listing the saved configs with the status of their proxies.
+type Model struct {
+	service Service
+	copy    func(text string) error
+
+	configs []config.ConfigParam
+	states  map[string]proxy.State
+	cursor  int
+	env     config.Environment
+	group   string
+	busy    map[string]string
+	message string
+	failed  bool
+	prompt  *prompt
+	logs    *logPane
+	width   int
+	height  int
+}
+
+func New(service Service) *Model {
+	return &Model{
+		service: service,
+		copy:    copyToClipboard,
+		states:  map[string]proxy.State{},
+		busy:    map[string]string{},
+	}
+}
+
+// Run shows the dashboard until the user quits.
+func Run(service Service) error {
+	m := New(service)
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	m.closeLogs()
+	return err
+}
+
+func (m *Model) Init() tea.Cmd {
+	return m.refresh
+}
+
+func (m *Model) refresh() tea.Msg {
+	configs, err := m.service.Configs()
+	if err != nil {
+		return refreshMsg{err: err}
+	}
+	states, err := m.service.States()
+	return refreshMsg{configs: configs, states: states, err: err}
+}
+
+// refreshOnce refreshes the configs and states without scheduling the next tick.
+func (m *Model) refreshOnce() tea.Msg {
+	msg := m.refresh().(refreshMsg)
+	msg.once = true
+	return msg
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(refreshInterval, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+	case tickMsg:
+		return m, m.refresh
+	case refreshMsg:
+		if msg.err != nil {
+			m.setError(msg.err)
+		} else {
+			m.configs = msg.configs
+			m.states = map[string]proxy.State{}
+			for _, state := range msg.states {
+				m.states[state.Name] = state
+			}
+			m.clampCursor()
+		}
+		if msg.once {
+			return m, nil
+		}
+		return m, tick()
+	case actionMsg:
+		delete(m.busy, msg.name)
+		if msg.err != nil {
+			m.setError(fmt.Errorf("%s %s: %w", msg.action, msg.name, msg.err))
+		} else {
+			m.setMessage(fmt.Sprintf("%s %s", msg.action, msg.name))
+		}
+		return m, m.refreshOnce
+	case copiedMsg:
+		if msg.err != nil {
+			m.setError(msg.err)
+		} else {
+			m.setMessage(fmt.Sprintf("copied the DSN of %s", msg.name))
+		}
+		return m, nil
+	case logMsg:
+		return m, m.updateLogs(msg)
+	case tea.KeyMsg:
+		if m.prompt != nil {
+			return m, m.updatePrompt(msg)
+		}
+		return m, m.handleKey(msg)
+	}
+	return m, nil
+}
+
+func (m *Model) handleKey(msg tea.KeyMsg) tea.Cmd {
+	rows := m.visible()
+	var selected *config.ConfigParam
+	if m.cursor < len(rows) {
+		selected = &rows[m.cursor]
+	}
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		m.closeLogs()
+		return tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(rows)-1 {
+			m.cursor++
+		}
+	case "e":
+		m.env = next(append([]config.Environment{""}, config.GetEnvironmentList()...), m.env)
+		m.clampCursor()
+	case "g":
+		m.group = next(append([]string{""}, m.groups()...), m.group)
+		m.clampCursor()
+	case "s":
+		if selected == nil {
+			return nil
+		}
+		if m.running(selected.Name) {
+			m.setError(fmt.Errorf("proxy for %q is already running", selected.Name))
+			return nil
+		}
+		return m.beginStart(*selected, false)
+	case "r":
+		if selected == nil {
+			return nil
+		}
+		return m.beginStart(*selected, m.running(selected.Name))
+	case "x":
+		if selected == nil {
+			return nil
+		}
+		return m.stop(selected.Name)
+	case "l":
+		if selected == nil {
+			return nil
+		}
+		if m.logs != nil && m.logs.name == selected.Name {
+			m.closeLogs()
+			return nil
+		}
+		return m.openLogs(selected.Name)
+	case "c":
+		if selected == nil {
+			return nil
+		}
+		name := selected.Name
+		return func() tea.Msg {
+			dsn, err := m.service.DSN(name)
+			if err == nil {
+				err = m.copy(dsn)
+			}
+			return copiedMsg{name: name, err: err}
+		}
+	}
+	return nil
+}
+
+// beginStart starts the proxy for the config after asking for the confirmation and the reason
+// the config requires, restarting it when restart is true.
+func (m *Model) beginStart(param config.ConfigParam, restart bool) tea.Cmd {
+	p := &prompt{param: param, restart: restart}
+	switch {
+	case param.IsProd():
+		p.kind = promptConfirm
+		p.input = newInput(fmt.Sprintf("%s connects to prod. Type the config name to confirm: ", param.Name))
+	case param.RequireReason:
+		p.kind = promptReason
+		p.input = newInput("Reason for connecting: ")
+	default:
+		return m.start(param, "", restart)
+	}
+	m.prompt = p
+	return textinput.Blink
+}
+
+func newInput(label string) textinput.Model {
+	input := textinput.New()
+	input.Prompt = label
+	input.Focus()
+	return input
+}
+
+func (m *Model) updatePrompt(msg tea.KeyMsg) tea.Cmd {
+	p := m.prompt
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.prompt = nil
+		m.setMessage("cancelled")
+		return nil
+	case "enter":
+		value := strings.TrimSpace(p.input.Value())
+		switch p.kind {
+		case promptConfirm:
+			if value != p.param.Name {
+				m.prompt = nil
+				m.setError(errors.New("confirmation does not match the config name"))
+				return nil
+			}
+			if p.param.RequireReason {
+				p.kind = promptReason
+				p.input = newInput("Reason for connecting: ")
+				return textinput.Blink
+			}
+		case promptReason:
+			if value == "" {
+				return nil
+			}
+			p.reason = value
+		}
+		m.prompt = nil
+		return m.start(p.param, p.reason, p.restart)
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return cmd
+}
+
+func (m *Model) start(param config.ConfigParam, reason string, restart bool) tea.Cmd {
+	action := "started"
+	m.busy[param.Name] = "starting"
+	if restart {
+		action = "restarted"
+		m.busy[param.Name] = "restarting"
+	}
+	return func() tea.Msg {
+		if restart {
+			err := m.service.Stop(param.Name)
+			if err != nil {
+				return actionMsg{name: param.Name, action: action, err: err}
+			}
+		}
+		err := m.service.Start(param, reason)
+		return actionMsg{name: param.Name, action: action, err: err}
+	}
+}
+
+func (m *Model) stop(name string) tea.Cmd {
+	m.busy[name] = "stopping"
+	return func() tea.Msg {
+		err := m.service.Stop(name)
+		return actionMsg{name: name, action: "stopped", err: err}
+	}
+}
+
+func (m *Model) openLogs(name string) tea.Cmd {
+	m.closeLogs()
+	ctx, cancel := context.WithCancel(context.Background())
+	pane := &logPane{name: name, events: make(chan logEvent, 256), cancel: cancel}
+	m.logs = pane
+	go func() {
+		defer close(pane.events)
+		err := m.service.Logs(ctx, name, func(line string) {
+			select {
+			case pane.events <- logEvent{line: line}:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil {
+			pane.events <- logEvent{err: err}
+		}
+	}()
+	return waitForLog(pane)
+}
+
+func waitForLog(pane *logPane) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-pane.events
+		return logMsg{name: pane.name, event: event, ok: ok}
+	}
+}
+
+func (m *Model) updateLogs(msg logMsg) tea.Cmd {
+	// Lines of a pane that was closed in the meantime
+	if m.logs == nil || m.logs.name != msg.name || !msg.ok {
+		return nil
+	}
+	line := msg.event.line
+	if msg.event.err != nil {
+		line = errorStyle.Render(msg.event.err.Error())
+	}
+	m.logs.lines = append(m.logs.lines, line)
+	if len(m.logs.lines) > logLines {
+		m.logs.lines = m.logs.lines[len(m.logs.lines)-logLines:]
+	}
+	return waitForLog(m.logs)
+}
+
+func (m *Model) closeLogs() {
+	if m.logs == nil {
+		return
+	}
+	m.logs.cancel()
+	// Let the follower exit when it is blocked sending a line
+	go func(events chan logEvent) {
+		for range events {
+		}
+	}(m.logs.events)
+	m.logs = nil
+}
+
+func (m *Model) setMessage(message string) {
+	m.message, m.failed = message, false
+}
+
+func (m *Model) setError(err error) {
+	m.message, m.failed = err.Error(), true
+}
+
+func (m *Model) running(name string) bool {
+	state, ok := m.states[name]
+	return ok && state.Alive()
+}
+
+// visible returns the configs matching the environment and group filters.
+func (m *Model) visible() []config.ConfigParam {
+	var rows []config.ConfigParam
+	for _, param := range m.configs {
+		if m.env != "" && param.Environment != m.env {
+			continue
+		}
+		if m.group != "" && param.Group != m.group {
+			continue
+		}
+		rows = append(rows, param)
+	}
+	return rows
+}
+
+func (m *Model) groups() []string {
+	var groups []string
+	for _, param := range m.configs {
+		if param.Group != "" && !slices.Contains(groups, param.Group) {
+			groups = append(groups, param.Group)
+		}
+	}
+	slices.Sort(groups)
+	return groups
+}
+
+func (m *Model) clampCursor() {
+	m.cursor = max(min(m.cursor, len(m.visible())-1), 0)
+}
+
+// next returns the value after current in values, wrapping around.
+func next[T comparable](values []T, current T) T {
+	i := slices.Index(values, current)
+	return values[(i+1)%len(values)]
+}
+
+func (m *Model) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("tsunagi"))
+	fmt.Fprintf(&b, "  environment: %s  group: %s\n\n", orAll(string(m.env)), orAll(m.group))
+
+	rows := m.visible()
+	table := [][]string{{"NAME", "ENV", "GROUP", "PORT", "STATUS", "UPTIME", "CONNS"}}
+	for _, param := range rows {
+		table = append(table, m.row(param))
+	}
+	widths := make([]int, len(table[0]))
+	for _, row := range table {
+		for i, cell := range row {
+			widths[i] = max(widths[i], lipgloss.Width(cell))
+		}
+	}
+	for i, row := range table {
+		var line string
+		switch {
+		case i == 0:
+			line = headerStyle.Render(formatRow(row, widths))
+		case i-1 == m.cursor:
+			line = selectedStyle.Render(formatRow(row, widths))
+		case rows[i-1].IsProd():
+			line = prodStyle.Render(formatRow(row, widths))
+		default:
+			row[4] = styleStatus(row[4])
+			line = formatRow(row, widths)
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(rows) == 0 {
+		b.WriteString(helpStyle.Render("no configs match the filters") + "\n")
+	}
+
+	if m.logs != nil {
+		b.WriteString("\n" + headerStyle.Render("logs: "+m.logs.name) + "\n")
+		for _, line := range tailLines(m.logs.lines, m.logHeight(len(table))) {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	b.WriteString("\n")
+	if m.prompt != nil {
+		b.WriteString(m.prompt.input.View() + "\n")
+	} else if m.message != "" {
+		if m.failed {
+			b.WriteString(errorStyle.Render(m.message) + "\n")
+		} else {
+			b.WriteString(m.message + "\n")
+		}
+	}
+	b.WriteString(helpStyle.Render("↑/↓ select  s start  x stop  r restart  l logs  c copy DSN  e environment  g group  q quit"))
+	return b.String()
+}
+
+func (m *Model) row(param config.ConfigParam) []string {
+	status, uptime, conns := "-", "-", "-"
+	if state, ok := m.states[param.Name]; ok {
+		status = string(state.Status())
+		if state.Alive() {
+			uptime = time.Since(state.StartedAt).Round(time.Second).String()
+		}
+		if state.Connections != nil {
+			conns = fmt.Sprintf("%d/%d", state.Connections.Active, state.Connections.Total)
+		}
+	}
+	if busy, ok := m.busy[param.Name]; ok {
+		status = busy + "…"
+	}
+	return []string{
+		param.Name,
+		orDash(string(param.Environment)),
+		orDash(param.Group),
+		fmt.Sprint(param.Port),
+		status,
+		uptime,
+		conns,
+	}
+}
+
+// styleStatus colours the status cell of a row. formatRow pads cells by their visible width.
+func styleStatus(status string) string {
+	style, ok := statusStyles[status]
+	if !ok {
+		return status
+	}
+	return style.Render(status)
+}
+
+// logHeight returns the number of log lines that fit below a table of rows lines.
+func (m *Model) logHeight(rows int) int {
+	if m.height == 0 {
+		return 10
+	}
+	// title, blank lines, logs header, message and help
+	return max(m.height-rows-7, 3)
+}
+
+func formatRow(row []string, widths []int) string {
+	cells := make([]string, len(row))
+	for i, cell := range row {
+		cells[i] = cell + strings.Repeat(" ", widths[i]-lipgloss.Width(cell))
+	}
+	return strings.TrimRight(strings.Join(cells, "  "), " ")
+}
+
+func tailLines(lines []string, n int) []string {
+	if len(lines) > n {
+		return lines[len(lines)-n:]
+	}
+	return lines
+}
+
+func orAll(value string) string {
+	if value == "" {
+		return "all"
+	}
+	return value
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
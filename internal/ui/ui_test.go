@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/config"
+	"github.com/kyoshidaxx/tsunagi/internal/domain/proxy"
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeService struct {
+	configs []config.ConfigParam
+	states  []proxy.State
+	started map[string]string
+	stopped []string
+}
+
+func (s *fakeService) Configs() ([]config.ConfigParam, error) { return s.configs, nil }
+func (s *fakeService) States() ([]proxy.State, error)         { return s.states, nil }
+
+func (s *fakeService) Start(param config.ConfigParam, reason string) error {
+	s.started[param.Name] = reason
+	return nil
+}
+
+func (s *fakeService) Stop(name string) error {
+	s.stopped = append(s.stopped, name)
+	return nil
+}
+
+func (s *fakeService) DSN(name string) (string, error) {
+	return "postgres://app@127.0.0.1:5432/" + name, nil
+}
+
+func (s *fakeService) Logs(ctx context.Context, name string, f func(line string)) error {
+	f("listening")
+	return nil
+}
+
+func newTestModel(t *testing.T) (*Model, *fakeService) {
+	service := &fakeService{
+		configs: []config.ConfigParam{
+			{Name: "billing", Port: 5432, Environment: config.EnvironmentDev, Group: "payments"},
+			{Name: "orders", Port: 5433, Environment: config.EnvironmentStaging},
+			{Name: "payments", Port: 5434, Environment: config.EnvironmentProd, Group: "payments", RequireReason: true},
+		},
+		states: []proxy.State{
+			{Name: "orders", Port: 5433, Supervisor: os.Getpid(), Phase: proxy.PhaseRunning},
+		},
+		started: map[string]string{},
+	}
+	m := New(service)
+	run(m, m.Init())
+	return m, service
+}
+
+// run runs cmd and the commands following from its message, except for ticks.
+func run(m *Model, cmd tea.Cmd) {
+	for cmd != nil {
+		msg := cmd()
+		if _, ok := msg.(refreshMsg); ok {
+			m.Update(msg)
+			return
+		}
+		if msg == nil {
+			return
+		}
+		_, cmd = m.Update(msg)
+	}
+}
+
+func key(m *Model, keys string) {
+	for _, r := range keys {
+		var msg tea.KeyMsg
+		switch r {
+		case '\n':
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}
+		}
+		_, cmd := m.Update(msg)
+		if m.prompt == nil {
+			run(m, cmd)
+		}
+	}
+}
+
+func names(params []config.ConfigParam) []string {
+	var names []string
+	for _, param := range params {
+		names = append(names, param.Name)
+	}
+	return names
+}
+
+func TestModel_Filters(t *testing.T) {
+	m, _ := newTestModel(t)
+	assert.Equal(t, []string{"billing", "orders", "payments"}, names(m.visible()))
+
+	key(m, "e")
+	assert.Equal(t, []string{"billing"}, names(m.visible()))
+	key(m, "ee")
+	assert.Equal(t, []string{"payments"}, names(m.visible()))
+	key(m, "e")
+	assert.Len(t, m.visible(), 3)
+
+	key(m, "g")
+	assert.Equal(t, []string{"billing", "payments"}, names(m.visible()))
+	key(m, "g")
+	assert.Len(t, m.visible(), 3)
+}
+
+func TestModel_StartStop(t *testing.T) {
+	m, service := newTestModel(t)
+
+	key(m, "s")
+	assert.Equal(t, map[string]string{"billing": ""}, service.started)
+
+	key(m, "js")
+	assert.Contains(t, m.message, "already running")
+	key(m, "x")
+	assert.Equal(t, []string{"orders"}, service.stopped)
+
+	key(m, "r")
+	assert.Equal(t, []string{"orders", "orders"}, service.stopped)
+	assert.Contains(t, service.started, "orders")
+}
+
+func TestModel_RefreshAfterAction(t *testing.T) {
+	m, _ := newTestModel(t)
+	// refresh runs cmd, which returns a refresh, and counts the ticks scheduled after it
+	refresh := func(cmd tea.Cmd) int {
+		require.NotNil(t, cmd)
+		msg, ok := cmd().(refreshMsg)
+		require.True(t, ok)
+		_, cmd = m.Update(msg)
+		if cmd == nil {
+			return 0
+		}
+		return 1
+	}
+
+	for range 3 {
+		_, cmd := m.Update(actionMsg{name: "billing", action: "started"})
+		assert.Equal(t, 0, refresh(cmd), "an action does not start another refresh loop")
+	}
+	_, cmd := m.Update(tickMsg{})
+	assert.Equal(t, 1, refresh(cmd))
+}
+
+func TestModel_StartProd(t *testing.T) {
+	t.Run("confirm and reason", func(t *testing.T) {
+		m, service := newTestModel(t)
+
+		key(m, "jjs")
+		require.NotNil(t, m.prompt)
+		key(m, "payments\n")
+		require.NotNil(t, m.prompt)
+		assert.Empty(t, service.started)
+		key(m, "\n")
+		require.NotNil(t, m.prompt, "an empty reason is not accepted")
+		key(m, "incident 42\n")
+
+		assert.Nil(t, m.prompt)
+		assert.Equal(t, map[string]string{"payments": "incident 42"}, service.started)
+	})
+
+	t.Run("wrong confirmation", func(t *testing.T) {
+		m, service := newTestModel(t)
+
+		key(m, "jjs")
+		key(m, "orders\n")
+
+		assert.Nil(t, m.prompt)
+		assert.True(t, m.failed)
+		assert.Empty(t, service.started)
+	})
+}
+
+func TestModel_CopyDSN(t *testing.T) {
+	m, _ := newTestModel(t)
+	var copied string
+	m.copy = func(text string) error {
+		copied = text
+		return nil
+	}
+
+	key(m, "c")
+
+	assert.Equal(t, "postgres://app@127.0.0.1:5432/billing", copied)
+	assert.False(t, m.failed)
+}
+
+func TestModel_View(t *testing.T) {
+	m, _ := newTestModel(t)
+
+	view := m.View()
+
+	lines := strings.Split(view, "\n")
+	assert.Contains(t, lines[2], "NAME")
+	assert.Contains(t, lines[4], "orders")
+	assert.Contains(t, lines[4], "running")
+	assert.Contains(t, lines[5], "payments")
+}
+
+func TestModel_View_StatusColor(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI)
+	t.Cleanup(func() {
+		lipgloss.SetColorProfile(profile)
+	})
+	m, service := newTestModel(t)
+	service.configs = append(service.configs,
+		config.ConfigParam{Name: "queue", Port: 5435},
+		config.ConfigParam{Name: "running-db", Port: 5436},
+	)
+	service.states = append(service.states,
+		proxy.State{Name: "queue", Port: 5435, Supervisor: os.Getpid(), Phase: proxy.PhaseRestarting},
+		proxy.State{Name: "running-db", Port: 5436, Supervisor: os.Getpid(), Phase: proxy.PhaseRunning},
+	)
+	run(m, m.refresh)
+
+	lines := strings.Split(m.View(), "\n")
+
+	// Only the status cell is coloured, not the status in the config name
+	line := lines[7]
+	styled := statusStyles[string(proxy.PhaseRunning)].Render("running")
+	assert.True(t, strings.HasPrefix(line, "running-db "))
+	assert.Contains(t, line, styled)
+	// The status cell is padded to the width of the column
+	plain := strings.Replace(line, styled, "running", 1)
+	uptime := strings.Index(lines[2], "UPTIME") - strings.Index(lines[2], "NAME")
+	assert.Equal(t, "  ", plain[uptime-2:uptime])
+	assert.NotEqual(t, byte(' '), plain[uptime])
+}
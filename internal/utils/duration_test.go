@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAge(t *testing.T) {
+	d, err := ParseAge("7d")
+	require.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, d)
+
+	d, err = ParseAge("90m")
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, d)
+
+	for _, s := range []string{"", "d", "1.5d", "-1d", "-1h", "week"} {
+		_, err = ParseAge(s)
+		assert.EqualError(t, err, "age is not valid", s)
+	}
+}
@@ -1,34 +1,232 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 )
 
 func CheckGcloudCmd() error {
-	cmd := exec.Command("gcloud", "--version")
-	_, err := cmd.Output()
+	_, err := GetGcloudVersion()
+	return err
+}
+
+// GetGcloudVersion returns the first line of gcloud --version, e.g. "Google Cloud SDK 470.0.0".
+func GetGcloudVersion() (string, error) {
+	out, err := runGcloud(GcloudContext{}, "--version")
 	if err != nil {
-		fmt.Println("gcloud command not found")
+		return "", fmt.Errorf("gcloud command not found: %w", err)
+	}
+	version, _, _ := strings.Cut(out, "\n")
+	return version, nil
+}
+
+// GcloudContext selects the gcloud configuration and the credentials that gcloud
+// and the Cloud SQL Auth Proxy run with instead of the globally active ones.
+type GcloudContext struct {
+	Configuration   string
+	CredentialsFile string
+}
+
+// Env returns the environment variables that select the context.
+func (c GcloudContext) Env() []string {
+	var env []string
+	if c.Configuration != "" {
+		env = append(env, "CLOUDSDK_ACTIVE_CONFIG_NAME="+c.Configuration)
+	}
+	if c.CredentialsFile != "" {
+		env = append(env, "GOOGLE_APPLICATION_CREDENTIALS="+c.CredentialsFile)
+	}
+	return env
+}
+
+// UsesGcloudCredentials reports whether the proxy authenticates with the credentials of
+// the gcloud configuration rather than with Application Default Credentials.
+func (c GcloudContext) UsesGcloudCredentials() bool {
+	return c.Configuration != "" && c.CredentialsFile == ""
+}
+
+// ErrCodeAuthRequired is the machine-readable code of an AuthError.
+const ErrCodeAuthRequired = "AUTH_REQUIRED"
+
+// AuthError reports that the gcloud credentials are missing or expired.
+type AuthError struct {
+	Context GcloudContext
+	Login   []string // gcloud arguments that renew the credentials
+	Err     error
+}
+
+func (e *AuthError) Error() string {
+	return "gcloud credentials are invalid or have expired, reauthenticate with: " + e.LoginCommand()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AuthError) Code() string {
+	return ErrCodeAuthRequired
+}
+
+// LoginCommand returns the command line the user can run to renew the credentials.
+func (e *AuthError) LoginCommand() string {
+	command := "gcloud " + strings.Join(e.Login, " ")
+	if e.Context.Configuration != "" {
+		command += " --configuration " + e.Context.Configuration
+	}
+	return command
+}
+
+// CheckGcloudAuth checks the credentials the proxy authenticates with and returns
+// an *AuthError when they are missing or expired.
+func CheckGcloudAuth(gc GcloudContext) error {
+	args := []string{"auth", "application-default", "print-access-token"}
+	login := []string{"auth", "application-default", "login"}
+	if gc.UsesGcloudCredentials() {
+		args = []string{"auth", "print-access-token"}
+		login = []string{"auth", "login"}
+	}
+	cmd := exec.Command("gcloud", args...)
+	cmd.Env = append(os.Environ(), gc.Env()...)
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
 		return err
 	}
+	if err != nil {
+		return &AuthError{Context: gc, Login: login, Err: err}
+	}
 	return nil
 }
 
-func CheckGcloudAuth() error {
-	cmd := exec.Command("gcloud", "auth", "application-default", "print-access-token")
-	err := cmd.Run()
+// Login runs the login command of the error interactively on the terminal.
+func Login(e *AuthError) error {
+	cmd := exec.Command("gcloud", e.Login...)
+	cmd.Env = append(os.Environ(), e.Context.Env()...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func GetDatabaseVersion(gc GcloudContext, projectName, instanceName string) (string, error) {
+	return runGcloud(gc, "sql", "instances", "describe", instanceName,
+		"--project", projectName,
+		"--format", "value(databaseVersion)",
+	)
+}
+
+// GetAccount returns the account of the active gcloud configuration.
+func GetAccount(gc GcloudContext) (string, error) {
+	account, err := runGcloud(gc, "config", "get-value", "account")
+	if err != nil {
+		return "", err
+	}
+	if account == "" {
+		return "", errors.New("no active gcloud account")
+	}
+	return account, nil
+}
+
+// GetConfiguredProjects returns the projects set in the gcloud configurations. They are read
+// from the local gcloud configuration, without calling the API.
+func GetConfiguredProjects() ([]string, error) {
+	out, err := runGcloud(GcloudContext{}, "config", "configurations", "list", "--format", "value(properties.core.project)")
+	if err != nil {
+		return nil, err
+	}
+	var projects []string
+	for _, project := range strings.Fields(out) {
+		if !slices.Contains(projects, project) {
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
+// CheckImpersonation verifies that the caller can mint tokens for the service account chain.
+// The chain is a comma separated list whose last entry is the target and the others are delegates.
+func CheckImpersonation(gc GcloudContext, chain string) error {
+	_, err := runGcloud(gc, "auth", "print-access-token", "--impersonate-service-account="+chain)
 	if err != nil {
-		fmt.Println("Your gcloud credentials are invalid or have expired.")
-		fmt.Println("Please reauthenticate by executing the following command.")
-		fmt.Println("")
-		fmt.Println("  gcloud auth application-default login")
-		fmt.Println("")
+		msg := err.Error()
+		if strings.Contains(msg, "PERMISSION_DENIED") || strings.Contains(msg, "getAccessToken") {
+			accounts := strings.Split(chain, ",")
+			return fmt.Errorf("cannot impersonate %s: the caller needs roles/iam.serviceAccountTokenCreator on it", accounts[len(accounts)-1])
+		}
 		return err
 	}
 	return nil
 }
 
+var secretVersionPattern = regexp.MustCompile(`^projects/([^/]+)/secrets/([^/]+)(?:/versions/([^/]+))?$`)
+
+// secretVersionCache keeps accessed secrets in memory for the lifetime of the process only.
+var secretVersionCache = struct {
+	sync.Mutex
+	values map[string]string
+}{values: map[string]string{}}
+
+// ValidSecretVersionName reports whether name is a Secret Manager resource name
+// such as projects/p/secrets/db-pass/versions/latest. The version may be omitted.
+func ValidSecretVersionName(name string) bool {
+	return secretVersionPattern.MatchString(name)
+}
+
+// AccessSecretVersion returns the payload of a Secret Manager secret version.
+// A secret without a version refers to the latest version.
+func AccessSecretVersion(gc GcloudContext, name string) (string, error) {
+	m := secretVersionPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", errors.New("secret name is not valid")
+	}
+	project, secret, version := m[1], m[2], m[3]
+	if version == "" {
+		version = "latest"
+	}
+
+	secretVersionCache.Lock()
+	defer secretVersionCache.Unlock()
+	if value, ok := secretVersionCache.values[name]; ok {
+		return value, nil
+	}
+	out, err := gcloudOutput(gc, "secrets", "versions", "access", version,
+		"--secret", secret,
+		"--project", project,
+	)
+	if err != nil {
+		return "", err
+	}
+	secretVersionCache.values[name] = string(out)
+	return string(out), nil
+}
+
+// runGcloud runs a gcloud command in the context and returns its trimmed standard output.
+func runGcloud(gc GcloudContext, args ...string) (string, error) {
+	out, err := gcloudOutput(gc, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func gcloudOutput(gc GcloudContext, args ...string) ([]byte, error) {
+	cmd := exec.Command("gcloud", args...)
+	cmd.Env = append(os.Environ(), gc.Env()...)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("gcloud %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
 func GetRegionList() []string {
 	return []string{
 		"asia-east1",              // Changhua County, Taiwan
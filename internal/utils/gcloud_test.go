@@ -1,9 +1,14 @@
 package utils
 
 import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetRegionList(t *testing.T) {
@@ -63,3 +68,209 @@ func TestGetRegionList_Order(t *testing.T) {
 		}
 	}
 }
+
+// setupFakeGcloud puts a fake gcloud script on PATH that prints stdout and exits with the given code.
+func setupFakeGcloud(t *testing.T, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "gcloud"), []byte("#!/bin/sh\n"+script+"\n"), 0755)
+	require.NoError(t, err)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestGetGcloudVersion(t *testing.T) {
+	setupFakeGcloud(t, `printf 'Google Cloud SDK 470.0.0\nbq 2.1.3\n'`)
+
+	version, err := GetGcloudVersion()
+	require.NoError(t, err)
+	assert.Equal(t, "Google Cloud SDK 470.0.0", version)
+	assert.NoError(t, CheckGcloudCmd())
+}
+
+func TestCheckGcloudCmd_NotFound(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := CheckGcloudCmd()
+	assert.ErrorContains(t, err, "gcloud command not found")
+}
+
+func TestGetDatabaseVersion(t *testing.T) {
+	setupFakeGcloud(t, `[ "$4 $6" = "test-instance test-project" ] || exit 1; echo POSTGRES_15`)
+
+	version, err := GetDatabaseVersion(GcloudContext{}, "test-project", "test-instance")
+	require.NoError(t, err)
+	assert.Equal(t, "POSTGRES_15", version)
+}
+
+func TestGetDatabaseVersion_Error(t *testing.T) {
+	setupFakeGcloud(t, `echo "instance not found" >&2; exit 1`)
+
+	_, err := GetDatabaseVersion(GcloudContext{}, "test-project", "test-instance")
+	assert.EqualError(t, err, "gcloud sql: instance not found")
+}
+
+func TestValidSecretVersionName(t *testing.T) {
+	assert.True(t, ValidSecretVersionName("projects/p/secrets/db-pass/versions/latest"))
+	assert.True(t, ValidSecretVersionName("projects/p/secrets/db-pass/versions/3"))
+	assert.True(t, ValidSecretVersionName("projects/p/secrets/db-pass"))
+	assert.False(t, ValidSecretVersionName("db-pass"))
+	assert.False(t, ValidSecretVersionName("projects/p/secrets/db-pass/versions/"))
+	assert.False(t, ValidSecretVersionName("projects/p/topics/db-pass"))
+}
+
+// resetSecretVersionCache empties the cache of secret values, which outlives a test run.
+func resetSecretVersionCache() {
+	secretVersionCache.Lock()
+	defer secretVersionCache.Unlock()
+	secretVersionCache.values = map[string]string{}
+}
+
+func TestAccessSecretVersion(t *testing.T) {
+	resetSecretVersionCache()
+	countFile := filepath.Join(t.TempDir(), "count")
+	setupFakeGcloud(t, `echo x >> `+countFile+`
+[ "$1 $2 $3 $4 $5 $6 $7 $8" = "secrets versions access 3 --secret db-pass --project test-project" ] || exit 1
+printf 'p@ss word '`)
+
+	value, err := AccessSecretVersion(GcloudContext{}, "projects/test-project/secrets/db-pass/versions/3")
+	require.NoError(t, err)
+	assert.Equal(t, "p@ss word ", value)
+
+	// The second access is served from the in-memory cache
+	value, err = AccessSecretVersion(GcloudContext{}, "projects/test-project/secrets/db-pass/versions/3")
+	require.NoError(t, err)
+	assert.Equal(t, "p@ss word ", value)
+
+	count, err := os.ReadFile(countFile)
+	require.NoError(t, err)
+	assert.Equal(t, "x\n", string(count))
+}
+
+func TestAccessSecretVersion_Latest(t *testing.T) {
+	setupFakeGcloud(t, `[ "$4" = "latest" ] || exit 1; printf secret`)
+
+	value, err := AccessSecretVersion(GcloudContext{}, "projects/test-project/secrets/latest-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", value)
+}
+
+func TestAccessSecretVersion_Error(t *testing.T) {
+	setupFakeGcloud(t, `echo "PERMISSION_DENIED" >&2; exit 1`)
+
+	_, err := AccessSecretVersion(GcloudContext{}, "projects/test-project/secrets/denied-pass/versions/1")
+	assert.EqualError(t, err, "gcloud secrets: PERMISSION_DENIED")
+
+	_, err = AccessSecretVersion(GcloudContext{}, "denied-pass")
+	assert.EqualError(t, err, "secret name is not valid")
+}
+
+func TestGetAccount(t *testing.T) {
+	setupFakeGcloud(t, `[ "$1 $2 $3" = "config get-value account" ] || exit 1; echo app@test-project.iam.gserviceaccount.com`)
+
+	account, err := GetAccount(GcloudContext{})
+	require.NoError(t, err)
+	assert.Equal(t, "app@test-project.iam.gserviceaccount.com", account)
+}
+
+func TestGetAccount_NotSet(t *testing.T) {
+	setupFakeGcloud(t, `echo "(unset)" >&2; echo ""`)
+
+	_, err := GetAccount(GcloudContext{})
+	assert.EqualError(t, err, "no active gcloud account")
+}
+
+func TestGetConfiguredProjects(t *testing.T) {
+	setupFakeGcloud(t, `[ "$1 $2 $3" = "config configurations list" ] || exit 1; printf 'test-project\n\nother-project\ntest-project\n'`)
+
+	projects, err := GetConfiguredProjects()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test-project", "other-project"}, projects)
+}
+
+func TestCheckImpersonation(t *testing.T) {
+	setupFakeGcloud(t, `[ "$3" = "--impersonate-service-account=reader@p.iam.gserviceaccount.com" ] || exit 1; echo token`)
+
+	err := CheckImpersonation(GcloudContext{}, "reader@p.iam.gserviceaccount.com")
+	assert.NoError(t, err)
+}
+
+func TestCheckImpersonation_PermissionDenied(t *testing.T) {
+	setupFakeGcloud(t, `echo "ERROR: (gcloud.auth.print-access-token) PERMISSION_DENIED: Permission 'iam.serviceAccounts.getAccessToken' denied" >&2; exit 1`)
+
+	err := CheckImpersonation(GcloudContext{}, "delegate@p.iam.gserviceaccount.com,reader@p.iam.gserviceaccount.com")
+	assert.EqualError(t, err, "cannot impersonate reader@p.iam.gserviceaccount.com: the caller needs roles/iam.serviceAccountTokenCreator on it")
+}
+
+func TestCheckImpersonation_OtherError(t *testing.T) {
+	setupFakeGcloud(t, `echo "network is unreachable" >&2; exit 1`)
+
+	err := CheckImpersonation(GcloudContext{}, "reader@p.iam.gserviceaccount.com")
+	assert.EqualError(t, err, "gcloud auth: network is unreachable")
+}
+
+func TestGcloudContext_Env(t *testing.T) {
+	assert.Empty(t, GcloudContext{}.Env())
+	assert.Equal(t, []string{
+		"CLOUDSDK_ACTIVE_CONFIG_NAME=client-a",
+		"GOOGLE_APPLICATION_CREDENTIALS=/keys/client-a.json",
+	}, GcloudContext{Configuration: "client-a", CredentialsFile: "/keys/client-a.json"}.Env())
+}
+
+func TestGcloudContext_UsesGcloudCredentials(t *testing.T) {
+	assert.False(t, GcloudContext{}.UsesGcloudCredentials())
+	assert.True(t, GcloudContext{Configuration: "client-a"}.UsesGcloudCredentials())
+	assert.False(t, GcloudContext{Configuration: "client-a", CredentialsFile: "/keys/client-a.json"}.UsesGcloudCredentials())
+}
+
+func TestGetAccount_WithContext(t *testing.T) {
+	setupFakeGcloud(t, `echo "$CLOUDSDK_ACTIVE_CONFIG_NAME@example.com"`)
+
+	account, err := GetAccount(GcloudContext{Configuration: "client-a"})
+	require.NoError(t, err)
+	assert.Equal(t, "client-a@example.com", account)
+}
+
+func TestCheckGcloudAuth(t *testing.T) {
+	setupFakeGcloud(t, `[ "$*" = "auth application-default print-access-token" ] && [ "$GOOGLE_APPLICATION_CREDENTIALS" = "/keys/a.json" ]`)
+
+	assert.NoError(t, CheckGcloudAuth(GcloudContext{CredentialsFile: "/keys/a.json"}))
+
+	err := CheckGcloudAuth(GcloudContext{})
+	var authErr *AuthError
+	require.ErrorAs(t, err, &authErr)
+	assert.Equal(t, ErrCodeAuthRequired, authErr.Code())
+	assert.Equal(t, "gcloud auth application-default login", authErr.LoginCommand())
+	assert.EqualError(t, err, "gcloud credentials are invalid or have expired, reauthenticate with: gcloud auth application-default login")
+}
+
+func TestCheckGcloudAuth_GcloudCredentials(t *testing.T) {
+	setupFakeGcloud(t, `[ "$*" = "auth print-access-token" ] && [ "$CLOUDSDK_ACTIVE_CONFIG_NAME" = "client-a" ]`)
+
+	assert.NoError(t, CheckGcloudAuth(GcloudContext{Configuration: "client-a"}))
+
+	err := CheckGcloudAuth(GcloudContext{Configuration: "client-b"})
+	var authErr *AuthError
+	require.ErrorAs(t, err, &authErr)
+	assert.Equal(t, "gcloud auth login --configuration client-b", authErr.LoginCommand())
+}
+
+func TestCheckGcloudAuth_GcloudNotFound(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := CheckGcloudAuth(GcloudContext{})
+	var authErr *AuthError
+	assert.False(t, errors.As(err, &authErr))
+	assert.ErrorIs(t, err, exec.ErrNotFound)
+}
+
+func TestLogin(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "args")
+	setupFakeGcloud(t, `echo "$* $CLOUDSDK_ACTIVE_CONFIG_NAME" > `+out)
+
+	err := Login(&AuthError{Context: GcloudContext{Configuration: "client-a"}, Login: []string{"auth", "login"}})
+	require.NoError(t, err)
+
+	args, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "auth login client-a\n", string(args))
+}